@@ -0,0 +1,344 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/client"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Version of the archive layout, bumped on incompatible changes.
+// Archives of version 1 are read too, they only have the names of the projects of members, not their memberships
+const Version = 2
+
+const manifestFile = "manifest.json"
+
+// Describes an archive, written to manifest.json
+type Manifest struct {
+	Version   int               `json:"version"`
+	Program   string            `json:"program"`
+	CreatedAt time.Time         `json:"created_at"`
+	Root      string            `json:"root"`
+	Members   int               `json:"members"`
+	Projects  int               `json:"projects"`
+	Checksums map[string]string `json:"checksums"` // sha256 of every other file, by path
+}
+
+// Whether the path names a .tar.gz archive rather than a directory
+func IsArchive(p string) bool {
+	return strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+// Writes the snapshot to a directory or, if p ends in .tar.gz or .tgz, to an archive
+func Write(s *Snapshot, p string, root string) (*Manifest, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Version:   Version,
+		Program:   client.ProgramName + "/" + client.ProgramVersion,
+		CreatedAt: time.Now().UTC(),
+		Root:      root,
+		Members:   len(s.Members),
+		Projects:  len(s.Projects),
+		Checksums: map[string]string{},
+	}
+	for name, data := range files {
+		m.Checksums[name] = checksum(data)
+	}
+	if files[manifestFile], err = json.MarshalIndent(m, "", "  "); err != nil {
+		return nil, err
+	}
+
+	if IsArchive(p) {
+		return m, writeArchive(files, p)
+	}
+	return m, writeDir(files, p)
+}
+
+// Reads a snapshot written by Write, verifying its version and checksums
+func Read(p string) (*Snapshot, *Manifest, error) {
+	var files map[string][]byte
+	var err error
+	if IsArchive(p) {
+		files, err = readArchive(p)
+	} else {
+		files, err = readDir(p)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var m Manifest
+	data, ok := files[manifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: missing %s", p, manifestFile)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if m.Version < 1 || m.Version > Version {
+		return nil, nil, fmt.Errorf("unsupported backup version %d, expected %d", m.Version, Version)
+	}
+	for name, sum := range m.Checksums {
+		data, ok := files[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: missing file %s", p, name)
+		}
+		if checksum(data) != sum {
+			return nil, nil, fmt.Errorf("%s: checksum mismatch for %s", p, name)
+		}
+	}
+	for name := range files {
+		if _, ok := m.Checksums[name]; !ok && name != manifestFile {
+			return nil, nil, fmt.Errorf("%s: unexpected file %s", p, name)
+		}
+	}
+
+	s, err := fromFiles(files, m.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, &m, nil
+}
+
+// Layout of the archive:
+//
+//	members.json                      all members
+//	projects.json                     all projects
+//	members/<username>/tags.json      tags of the member
+//	members/<username>/projects.json  memberships of the member, by project name
+//	members/<username>/logo.<ext>     logo of the member, if any
+//	projects/<name>/logo.<ext>        logo of the project, if any
+func (s *Snapshot) files() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	add := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("json.Marshal %s: %w", name, err)
+		}
+		files[name] = data
+		return nil
+	}
+
+	if err := add("members.json", s.Members); err != nil {
+		return nil, err
+	}
+	if err := add("projects.json", s.Projects); err != nil {
+		return nil, err
+	}
+	for _, m := range s.Members {
+		dir := path.Join("members", url.PathEscape(m.Username))
+		if err := add(path.Join(dir, "tags.json"), orEmpty(s.Tags[m.Username])); err != nil {
+			return nil, err
+		}
+		memberships := s.Memberships[m.Username]
+		if memberships == nil {
+			memberships = map[string]client.Membership{}
+		}
+		if err := add(path.Join(dir, "projects.json"), memberships); err != nil {
+			return nil, err
+		}
+		if logo, ok := s.MemberLogos[m.Username]; ok {
+			files[path.Join(dir, logo.Filename)] = logo.Data
+		}
+	}
+	for _, p := range s.Projects {
+		if logo, ok := s.ProjectLogos[p.Name]; ok {
+			files[path.Join("projects", url.PathEscape(p.Name), logo.Filename)] = logo.Data
+		}
+	}
+	return files, nil
+}
+
+func fromFiles(files map[string][]byte, version int) (*Snapshot, error) {
+	s := NewSnapshot()
+	read := func(name string, v any) error {
+		data, ok := files[name]
+		if !ok {
+			return fmt.Errorf("missing file %s", name)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := read("members.json", &s.Members); err != nil {
+		return nil, err
+	}
+	if err := read("projects.json", &s.Projects); err != nil {
+		return nil, err
+	}
+	for _, m := range s.Members {
+		dir := path.Join("members", url.PathEscape(m.Username))
+		var tags []string
+		if err := read(path.Join(dir, "tags.json"), &tags); err != nil {
+			return nil, err
+		}
+		memberships := map[string]client.Membership{}
+		if version == 1 {
+			var projects []string
+			if err := read(path.Join(dir, "projects.json"), &projects); err != nil {
+				return nil, err
+			}
+			for _, p := range projects {
+				memberships[p] = client.Membership{}
+			}
+		} else if err := read(path.Join(dir, "projects.json"), &memberships); err != nil {
+			return nil, err
+		}
+		s.Tags[m.Username] = tags
+		s.Memberships[m.Username] = memberships
+		if logo, ok := findLogo(files, dir); ok {
+			s.MemberLogos[m.Username] = logo
+		}
+	}
+	for _, p := range s.Projects {
+		if logo, ok := findLogo(files, path.Join("projects", url.PathEscape(p.Name))); ok {
+			s.ProjectLogos[p.Name] = logo
+		}
+	}
+	return s, nil
+}
+
+func findLogo(files map[string][]byte, dir string) (Logo, bool) {
+	for name, data := range files {
+		if path.Dir(name) == dir && strings.HasPrefix(path.Base(name), "logo") {
+			return Logo{Filename: path.Base(name), Data: data}, true
+		}
+	}
+	return Logo{}, false
+}
+
+func orEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Backups hold personal data of members, like the journal, so only the user can read them
+func writeDir(files map[string][]byte, dir string) error {
+	// Stray files would fail the checks on read
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s: directory not empty", dir)
+	}
+	for _, name := range sortedNames(files) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, files[name], 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDir(dir string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	return files, err
+}
+
+// Only the user can read the archive and the files extracted from it, see writeDir
+func writeArchive(files map[string][]byte, p string) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, name := range sortedNames(files) {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(files[name])), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(p, buf.Bytes(), 0o600)
+}
+
+func readArchive(p string) (map[string][]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(hdr.Name)] = data
+	}
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"encoding/json"
+	"hscli/client"
+	"hscli/fakeapi"
+	"io/fs"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Adds an admin to the fake API, starts it and returns a client logged in as them
+func setup(t *testing.T, s *fakeapi.Server) *client.Client {
+	t.Helper()
+	s.AddMember(client.Member{Username: "admin", Name: "Admin"}, "secret")
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	c := client.NewClient()
+	c.Cfg.Root = srv.URL
+	c.Cfg.User = "admin"
+	c.Cfg.Password = "secret"
+	c.Cfg.CookieJarPath = filepath.Join(t.TempDir(), "cookiejar.json")
+	c.SetupJar()
+	if err := c.Login(); err != nil {
+		t.Fatalf("Login: %s", err)
+	}
+	return c
+}
+
+func TestWriteRead(t *testing.T) {
+	s := fakeapi.New()
+	s.AddMember(client.Member{Username: "john", Name: "John"}, "john-pw")
+	s.AddProject(client.Project{Name: "web", State: "active"})
+	s.AddMembership("john", "web", client.Membership{EntryDate: "2023-09-01", Contributions: "site"})
+	snapshot, err := Take(setup(t, s))
+	if err != nil {
+		t.Fatalf("Take: %s", err)
+	}
+	want := map[string]client.Membership{"web": {EntryDate: "2023-09-01", Contributions: "site"}}
+	if !reflect.DeepEqual(snapshot.Memberships["john"], want) {
+		t.Fatalf("Memberships of john = %+v, want %+v", snapshot.Memberships["john"], want)
+	}
+
+	dir := t.TempDir()
+	for _, p := range []string{filepath.Join(dir, "backup"), filepath.Join(dir, "backup.tar.gz")} {
+		if _, err := Write(snapshot, p, "http://fakeapi"); err != nil {
+			t.Fatalf("Write %s: %s", p, err)
+		}
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if perm := info.Mode().Perm(); perm&0o077 != 0 {
+				t.Errorf("permissions of %s = %o, want none for others", path, perm)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read, _, err := Read(p)
+		if err != nil {
+			t.Fatalf("Read %s: %s", p, err)
+		}
+		if !reflect.DeepEqual(read.Memberships, snapshot.Memberships) {
+			t.Errorf("Memberships read from %s = %+v, want %+v", p, read.Memberships, snapshot.Memberships)
+		}
+	}
+
+	target := fakeapi.New()
+	c := setup(t, target)
+	if _, err := Restore(c, snapshot, RestoreOptions{}); err != nil {
+		t.Fatalf("Restore: %s", err)
+	}
+	ms, err := c.Members.Membership("john", "web")
+	if err != nil || *ms != want["web"] {
+		t.Errorf("Membership after restore = %+v, %v, want %+v", ms, err, want["web"])
+	}
+}
+
+// Archives of version 1 only list the names of the projects of members
+func TestReadVersion1(t *testing.T) {
+	s, m, err := Read(filepath.Join("..", "testdata", "backup"))
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if m.Version != 1 {
+		t.Fatalf("version = %d, want the testdata to be of version 1", m.Version)
+	}
+	want := map[string]client.Membership{"web": {}}
+	if !reflect.DeepEqual(s.Memberships["john"], want) {
+		t.Errorf("Memberships of john = %+v, want %+v", s.Memberships["john"], want)
+	}
+}
+
+func TestAnonymise(t *testing.T) {
+	s := NewSnapshot()
+	s.Members = []client.Member{{
+		Username: "john", Name: "John", Email: "john@example.org", IstID: "ist1123456", JoinDate: "2023-09-01", Extra: "x",
+		Unknown: client.UnknownFields{"phone": json.RawMessage(`"912345678"`)},
+	}}
+	s.Projects = []client.Project{{Name: "web", Unknown: client.UnknownFields{"lead_email": json.RawMessage(`"john@example.org"`)}}}
+	s.Memberships["john"] = map[string]client.Membership{"web": {EntryDate: "2023-09-01", Contributions: "John's site"}}
+	s.MemberLogos["john"] = Logo{Filename: "logo.png", Data: []byte("john")}
+	s.Anonymise()
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, personal := range []string{"John", "example.org", "ist1123456", "2023", "912345678", `"x"`} {
+		if strings.Contains(string(data), personal) {
+			t.Errorf("anonymised snapshot %s contains %s", data, personal)
+		}
+	}
+	if _, ok := s.Memberships["john"]["web"]; !ok {
+		t.Errorf("membership of john in web dropped, want it kept")
+	}
+}
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hscli/client"
+	"hscli/logging"
+	"hscli/patch"
+	"maps"
+	"slices"
+)
+
+type RestoreOptions struct {
+	SkipExisting bool // Leaves members and projects already in the API untouched, instead of overwriting them
+
+	// Creates and overwrites members and projects through it, unless it's nil, e.g. to record what they were
+	Journal func(kind, name string, change func() error) error
+}
+
+// What a restore did
+type Report struct {
+	ProjectsCreated  int `json:"projects_created"`
+	ProjectsUpdated  int `json:"projects_updated"`
+	ProjectsSkipped  int `json:"projects_skipped"`
+	MembersCreated   int `json:"members_created"`
+	MembersUpdated   int `json:"members_updated"`
+	MembersSkipped   int `json:"members_skipped"`
+	TagsAdded        int `json:"tags_added"`
+	MembershipsAdded int `json:"memberships_added"`
+	LogosUploaded    int `json:"logos_uploaded"`
+}
+
+// Replays the snapshot into the API in dependency order: projects, members, tags, memberships and logos.
+// Tags and memberships are only added, never removed. Stops at the first failure, returning what was done so far
+func Restore(c *client.Client, s *Snapshot, opts RestoreOptions) (*Report, error) {
+	r := &Report{}
+
+	liveProjects, err := c.Projects.List()
+	if err != nil {
+		return r, err
+	}
+	liveMembers, err := c.Members.List()
+	if err != nil {
+		return r, err
+	}
+
+	skipped := map[string]bool{} // members and projects left untouched, keyed by kind and name
+	for _, p := range s.Projects {
+		i := slices.IndexFunc(liveProjects, func(l client.Project) bool { return l.Name == p.Name })
+		exists := i >= 0
+		switch {
+		case exists && opts.SkipExisting:
+			logging.LogDebug("Skipping existing project %s", p.Name)
+			skipped["project/"+p.Name] = true
+			r.ProjectsSkipped++
+		case exists:
+			err := opts.journal("project", p.Name, func() error {
+				doc, err := replacement(p, liveProjects[i])
+				if err == nil {
+					_, err = c.Projects.Update(p.Name, doc)
+				}
+				return err
+			})
+			if err != nil {
+				return r, fmt.Errorf("update project %s: %w", p.Name, err)
+			}
+			r.ProjectsUpdated++
+		default:
+			err := opts.journal("project", p.Name, func() error {
+				_, err := c.Projects.Create(&p)
+				return err
+			})
+			if err != nil {
+				return r, fmt.Errorf("create project %s: %w", p.Name, err)
+			}
+			r.ProjectsCreated++
+		}
+	}
+
+	for _, m := range s.Members {
+		i := slices.IndexFunc(liveMembers, func(l client.Member) bool { return l.Username == m.Username })
+		exists := i >= 0
+		switch {
+		case exists && opts.SkipExisting:
+			logging.LogDebug("Skipping existing member %s", m.Username)
+			skipped["member/"+m.Username] = true
+			r.MembersSkipped++
+		case exists:
+			err := opts.journal("member", m.Username, func() error {
+				doc, err := replacement(m, liveMembers[i])
+				if err == nil {
+					_, err = c.Members.Update(m.Username, doc)
+				}
+				return err
+			})
+			if err != nil {
+				return r, fmt.Errorf("update member %s: %w", m.Username, err)
+			}
+			r.MembersUpdated++
+		default:
+			err := opts.journal("member", m.Username, func() error {
+				_, err := c.Members.Create(&m)
+				return err
+			})
+			if err != nil {
+				return r, fmt.Errorf("create member %s: %w", m.Username, err)
+			}
+			r.MembersCreated++
+		}
+	}
+
+	for _, m := range s.Members {
+		if skipped["member/"+m.Username] || len(s.Tags[m.Username]) == 0 {
+			continue
+		}
+		live, err := c.Members.Tags(m.Username)
+		if err != nil {
+			return r, fmt.Errorf("tags of member %s: %w", m.Username, err)
+		}
+		for _, tag := range s.Tags[m.Username] {
+			if slices.Contains(live, tag) {
+				continue
+			}
+			if err := c.Members.AddTag(m.Username, tag); err != nil {
+				return r, fmt.Errorf("add tag %s to member %s: %w", tag, m.Username, err)
+			}
+			r.TagsAdded++
+		}
+	}
+
+	for _, m := range s.Members {
+		if skipped["member/"+m.Username] || len(s.Memberships[m.Username]) == 0 {
+			continue
+		}
+		live, err := c.Members.Projects(m.Username)
+		if err != nil {
+			return r, fmt.Errorf("projects of member %s: %w", m.Username, err)
+		}
+		for _, project := range slices.Sorted(maps.Keys(s.Memberships[m.Username])) {
+			if slices.ContainsFunc(live, func(l client.Project) bool { return l.Name == project }) {
+				continue
+			}
+			ms := s.Memberships[m.Username][project]
+			if err := c.Members.AddProject(m.Username, project, &ms); err != nil {
+				return r, fmt.Errorf("add member %s to project %s: %w", m.Username, project, err)
+			}
+			r.MembershipsAdded++
+		}
+	}
+
+	for _, p := range s.Projects {
+		logo, ok := s.ProjectLogos[p.Name]
+		if !ok || skipped["project/"+p.Name] {
+			continue
+		}
+		if err := c.Projects.UploadLogo(p.Name, logo.Filename, bytes.NewReader(logo.Data)); err != nil {
+			return r, fmt.Errorf("upload logo of project %s: %w", p.Name, err)
+		}
+		r.LogosUploaded++
+	}
+	for _, m := range s.Members {
+		logo, ok := s.MemberLogos[m.Username]
+		if !ok || skipped["member/"+m.Username] {
+			continue
+		}
+		if err := c.Members.UploadLogo(m.Username, logo.Filename, bytes.NewReader(logo.Data)); err != nil {
+			return r, fmt.Errorf("upload logo of member %s: %w", m.Username, err)
+		}
+		r.LogosUploaded++
+	}
+	return r, nil
+}
+
+// The record as a document replacing the live one, so the fields it omits as empty are cleared, see patch.Replace
+func replacement(record, live any) (map[string]any, error) {
+	var fields, liveFields map[string]any
+	if err := convert(record, &fields); err != nil {
+		return nil, err
+	}
+	if err := convert(live, &liveFields); err != nil {
+		return nil, err
+	}
+	return patch.Replace(fields, liveFields), nil
+}
+
+// Converts between JSON compatible values, e.g. a client.Member into a map
+func convert(from, to any) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := json.Unmarshal(data, to); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return nil
+}
+
+// Makes the change through Journal, if set
+func (opts RestoreOptions) journal(kind, name string, change func() error) error {
+	if opts.Journal == nil {
+		return change()
+	}
+	return opts.Journal(kind, name, change)
+}
@@ -0,0 +1,160 @@
+// Snapshots of all the data of the API, written to and read from directories or .tar.gz archives
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"hscli/client"
+	"hscli/logging"
+	"mime"
+	"net/http"
+)
+
+// All the data of the API at a point in time
+type Snapshot struct {
+	Members      []client.Member
+	Projects     []client.Project
+	Tags         map[string][]string                     // by username
+	Memberships  map[string]map[string]client.Membership // by username and project name
+	MemberLogos  map[string]Logo                         // by username
+	ProjectLogos map[string]Logo                         // by project name
+}
+
+type Logo struct {
+	Filename string // e.g. "logo.png", the extension is used to detect the content type on upload
+	Data     []byte
+}
+
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Tags:         map[string][]string{},
+		Memberships:  map[string]map[string]client.Membership{},
+		MemberLogos:  map[string]Logo{},
+		ProjectLogos: map[string]Logo{},
+	}
+}
+
+// Reads everything from the API: members, projects, each member's tags and memberships and all logos
+func Take(c *client.Client) (*Snapshot, error) {
+	s := NewSnapshot()
+
+	var err error
+	if s.Projects, err = c.Projects.List(); err != nil {
+		return nil, err
+	}
+	for _, p := range s.Projects {
+		if err := s.takeProjectLogo(c, p.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Members, err = c.Members.List(); err != nil {
+		return nil, err
+	}
+	for _, m := range s.Members {
+		logging.LogDebug("Backing up member %s", m.Username)
+		if err := s.takeMemberData(c, m.Username); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Reads a single member with its tags, memberships and logo
+func TakeMember(c *client.Client, username string) (*Snapshot, error) {
+	m, err := c.Members.Get(username)
+	if err != nil {
+		return nil, err
+	}
+	s := NewSnapshot()
+	s.Members = []client.Member{*m}
+	if err := s.takeMemberData(c, username); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reads a single project with its logo and the memberships of its members
+func TakeProject(c *client.Client, name string) (*Snapshot, error) {
+	p, err := c.Projects.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	s := NewSnapshot()
+	s.Projects = []client.Project{*p}
+	members, err := c.Projects.Members(name)
+	if err != nil {
+		return nil, fmt.Errorf("members of project %s: %w", name, err)
+	}
+	for _, m := range members {
+		ms, err := c.Members.Membership(m.Username, name)
+		if err != nil {
+			return nil, fmt.Errorf("membership of member %s in project %s: %w", m.Username, name, err)
+		}
+		s.Memberships[m.Username] = map[string]client.Membership{name: *ms}
+	}
+	if err := s.takeProjectLogo(c, name); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Snapshot) takeMemberData(c *client.Client, username string) error {
+	tags, err := c.Members.Tags(username)
+	if err != nil {
+		return fmt.Errorf("tags of member %s: %w", username, err)
+	}
+	s.Tags[username] = tags
+
+	projects, err := c.Members.Projects(username)
+	if err != nil {
+		return fmt.Errorf("projects of member %s: %w", username, err)
+	}
+	s.Memberships[username] = map[string]client.Membership{}
+	for _, p := range projects {
+		ms, err := c.Members.Membership(username, p.Name)
+		if err != nil {
+			return fmt.Errorf("membership of member %s in project %s: %w", username, p.Name, err)
+		}
+		s.Memberships[username][p.Name] = *ms
+	}
+
+	logo, err := fetchLogo(func() ([]byte, error) { return c.Members.Logo(username) })
+	if err != nil {
+		return fmt.Errorf("logo of member %s: %w", username, err)
+	}
+	if logo != nil {
+		s.MemberLogos[username] = *logo
+	}
+	return nil
+}
+
+func (s *Snapshot) takeProjectLogo(c *client.Client, name string) error {
+	logo, err := fetchLogo(func() ([]byte, error) { return c.Projects.Logo(name) })
+	if err != nil {
+		return fmt.Errorf("logo of project %s: %w", name, err)
+	}
+	if logo != nil {
+		s.ProjectLogos[name] = *logo
+	}
+	return nil
+}
+
+// Fetches a logo, nil if there's none
+func fetchLogo(get func() ([]byte, error)) (*Logo, error) {
+	data, err := get()
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	filename := "logo"
+	if exts, _ := mime.ExtensionsByType(http.DetectContentType(data)); len(exts) > 0 {
+		filename += exts[0]
+	}
+	return &Logo{Filename: filename, Data: data}, nil
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"fmt"
+	"hscli/client"
+	"slices"
+)
+
+// Renames members, mapping is old usernames to new ones
+func (s *Snapshot) RenameMembers(mapping map[string]string) error {
+	exists := func(username string) bool {
+		return slices.ContainsFunc(s.Members, func(m client.Member) bool { return m.Username == username })
+	}
+	targets := map[string]string{}
+	for from, to := range mapping {
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("cannot rename both %s and %s to %s", other, from, to)
+		}
+		targets[to] = from
+		if !exists(from) {
+			return fmt.Errorf("no member %s to rename", from)
+		}
+		// Taken unless that member is renamed as well
+		if _, renamed := mapping[to]; exists(to) && !renamed {
+			return fmt.Errorf("cannot rename %s to %s, the username is taken", from, to)
+		}
+	}
+
+	for i, m := range s.Members {
+		if to, ok := mapping[m.Username]; ok {
+			s.Members[i].Username = to
+		}
+	}
+	s.Tags = renameKeys(s.Tags, mapping)
+	s.Memberships = renameKeys(s.Memberships, mapping)
+	s.MemberLogos = renameKeys(s.MemberLogos, mapping)
+	return nil
+}
+
+func renameKeys[V any](m map[string]V, mapping map[string]string) map[string]V {
+	renamed := make(map[string]V, len(m))
+	for k, v := range m {
+		if to, ok := mapping[k]; ok {
+			k = to
+		}
+		renamed[k] = v
+	}
+	return renamed
+}
+
+// Replaces the personal fields of members with placeholders and clears their dates, the records of their memberships and their logos.
+// The fields the client types don't declare may be personal too, they're dropped from members and projects.
+// Usernames are kept, use RenameMembers to change them
+func (s *Snapshot) Anonymise() {
+	for i := range s.Members {
+		m := &s.Members[i]
+		m.Name = fmt.Sprintf("Member %d", i+1)
+		m.Email = m.Username + "@example.com"
+		if m.IstID != "" {
+			m.IstID = fmt.Sprintf("ist1%06d", i+1)
+		}
+		m.JoinDate = ""
+		m.ExitDate = ""
+		m.Description = ""
+		m.Extra = ""
+		m.Unknown = nil
+	}
+	for i := range s.Projects {
+		s.Projects[i].Unknown = nil
+	}
+	for _, memberships := range s.Memberships {
+		for project := range memberships {
+			memberships[project] = client.Membership{}
+		}
+	}
+	s.MemberLogos = map[string]Logo{}
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hscli/logging"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// Version of the cassette file format, bumped on incompatible changes
+const CassetteVersion = 1
+
+// Requests and responses recorded by WithRecordingRoundTripper, with credentials redacted
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu   sync.Mutex
+	used []bool // interactions already replayed
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   RecordedBody `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int          `json:"status_code"`
+	Header     http.Header  `json:"header,omitempty"`
+	Body       RecordedBody `json:"body,omitempty"`
+}
+
+// Body stored as text when it's valid UTF-8, base64 otherwise, e.g. logos
+type RecordedBody []byte
+
+func (b RecordedBody) MarshalJSON() ([]byte, error) {
+	v := map[string]string{"base64": base64.StdEncoding.EncodeToString(b)}
+	if utf8.Valid(b) {
+		v = map[string]string{"text": string(b)}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), err
+}
+
+func (b *RecordedBody) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Text   string `json:"text"`
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Base64 == "" {
+		*b = []byte(v.Text)
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(v.Base64)
+	*b = decoded
+	return err
+}
+
+func ReadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Version != CassetteVersion {
+		return nil, fmt.Errorf("%s: unsupported cassette version %d, expected %d", path, c.Version, CassetteVersion)
+	}
+	c.used = make([]bool, len(c.Interactions))
+	return &c, nil
+}
+
+type WithRecordingRoundTripper struct {
+	r        http.RoundTripper
+	path     string
+	cassette *Cassette
+}
+
+// Decorator to record every request and response to the cassette file, rewritten after each one
+func (rrt WithRecordingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := rrt.r.RoundTrip(r)
+	if err != nil {
+		return rsp, err
+	}
+	rspBody, err := readBody(&rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rrt.cassette.mu.Lock()
+	defer rrt.cassette.mu.Unlock()
+	rrt.cassette.Interactions = append(rrt.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: r.Method,
+			URL:    r.URL.String(),
+			Header: DefaultRedactor.Header(r.Header),
+			Body:   DefaultRedactor.Body(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: rsp.StatusCode,
+			Header:     DefaultRedactor.Header(rsp.Header),
+			Body:       DefaultRedactor.Body(rspBody),
+		},
+	})
+	if err := writeJSONFile(rrt.path, rrt.cassette); err != nil {
+		logging.Error("Failed writing cassette", "path", rrt.path, "error", err)
+	}
+	return rsp, nil
+}
+
+// Writes v as indented JSON, readable only by the user as it may hold personal data
+func writeJSONFile(path string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // keeps <redacted> readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
+
+type WithReplayRoundTripper struct {
+	cassette *Cassette
+}
+
+// Transport answering requests with the responses of a cassette, without any network.
+// Each request gets the first response recorded for the same method, path and query that wasn't replayed yet
+func (rrt WithReplayRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if _, err := readBody(&r.Body); err != nil {
+		return nil, err
+	}
+
+	rrt.cassette.mu.Lock()
+	defer rrt.cassette.mu.Unlock()
+	for i, in := range rrt.cassette.Interactions {
+		if rrt.cassette.used[i] || in.Request.Method != r.Method || !sameResource(in.Request.URL, r) {
+			continue
+		}
+		rrt.cassette.used[i] = true
+		logging.Debug("Replaying request", "method", r.Method, "endpoint", r.URL.Path, "interaction", i+1)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       r,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response left for %s %s", r.Method, r.URL)
+}
+
+// Whether a recorded URL has the path and query of the request, the host may differ
+func sameResource(recorded string, r *http.Request) bool {
+	u, err := r.URL.Parse(recorded)
+	return err == nil && u.EscapedPath() == r.URL.EscapedPath() && u.RawQuery == r.URL.RawQuery
+}
+
+// Reads a request or response body, leaving a fresh reader in its place
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
@@ -1,9 +1,25 @@
 package client
 
 import (
-	"errors"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"hscli/config"
+	"hscli/logging"
+	"hscli/store"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	stdcookiejar "net/http/cookiejar"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"go.nhat.io/cookiejar"
@@ -15,34 +31,243 @@ const (
 	ProgramVersion = "0.0.1"
 )
 
-var ErrUnauthorized = errors.New("Unauthorized!")
+const (
+	defaultTimeout         = 100 * time.Second
+	defaultConnectTimeout  = 30 * time.Second
+	defaultMaxAttempts     = 3
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+)
 
 type Client struct {
-	Http *http.Client
-	Cfg  *config.Config
+	Http   *http.Client
+	Cfg    *config.Config
+	Stdout io.Writer // where dry runs write the requests they would send
+
+	dryRunWritten int // requests written by a dry run, see DryRunWritten
+
+	Members  *MembersService
+	Projects *ProjectsService
 }
 
 func NewClient() *Client {
-	return &Client{
+	c := &Client{
 		Http: &http.Client{
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse // don't follow redirects
 			},
-			Transport: WithUARoundTripper{
-				r: WithLoggingRoundTripper{
-					r: http.DefaultTransport,
-				},
-			},
-			Timeout: 100 * time.Second, // default, TODO should be passed as CLI arg
+			Transport: newTransport(http.DefaultTransport, WithRetryRoundTripper{
+				MaxAttempts: defaultMaxAttempts,
+				Backoff:     ExponentialBackoff(defaultRetryBackoff, defaultRetryMaxBackoff),
+			}),
+			Timeout: defaultTimeout,
 		},
-		Cfg: &config.Config{},
+		Cfg:    &config.Config{},
+		Stdout: os.Stdout,
+	}
+	c.Members = &MembersService{c: c}
+	c.Projects = &ProjectsService{c: c}
+	return c
+}
+
+// Chain of decorators around the base transport, retry is the retry policy to use
+func newTransport(base http.RoundTripper, retry WithRetryRoundTripper) http.RoundTripper {
+	retry.r = WithLoggingRoundTripper{
+		r: base,
+	}
+	return WithUARoundTripper{
+		r: retry,
 	}
 }
 
+// Builds the transport chain from the configuration
+func (c *Client) SetupTransport() error {
+	retry := WithRetryRoundTripper{
+		MaxAttempts: c.Cfg.MaxAttempts,
+		RetryAll:    c.Cfg.RetryPost,
+	}
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = defaultMaxAttempts
+	}
+	base, err := parseDuration(c.Cfg.RetryBackoff, defaultRetryBackoff)
+	if err != nil {
+		return fmt.Errorf("retry_backoff: %w", err)
+	}
+	max, err := parseDuration(c.Cfg.RetryMaxBackoff, defaultRetryMaxBackoff)
+	if err != nil {
+		return fmt.Errorf("retry_max_backoff: %w", err)
+	}
+	retry.Backoff = ExponentialBackoff(base, max)
+
+	timeout, err := parseDuration(c.Cfg.Timeout, defaultTimeout)
+	if err != nil {
+		return fmt.Errorf("timeout: %w", err)
+	}
+	var transport http.RoundTripper
+	switch {
+	case c.Cfg.Record != "" && c.Cfg.Replay != "":
+		return fmt.Errorf("record and replay can't be used together")
+	case c.Cfg.Replay != "":
+		cassette, err := ReadCassette(c.Cfg.Replay)
+		if err != nil {
+			return fmt.Errorf("replay: %w", err)
+		}
+		transport = WithReplayRoundTripper{cassette: cassette}
+		retry.Backoff = func(int) time.Duration { return 0 } // nothing to wait for
+	default:
+		if transport, err = c.baseTransport(); err != nil {
+			return err
+		}
+		if c.Cfg.Record != "" {
+			transport = WithRecordingRoundTripper{r: transport, path: c.Cfg.Record, cassette: &Cassette{Version: CassetteVersion}}
+		}
+	}
+	if c.Cfg.HAR != "" {
+		transport = WithHARRoundTripper{r: transport, path: c.Cfg.HAR, har: NewHAR(), redactor: ParseRedactor(c.Cfg.HARRedact)}
+	}
+	if c.DryRun() {
+		if c.Cfg.Explain != "" && !slices.Contains(ExplainFormats, c.Cfg.Explain) {
+			return fmt.Errorf("explain: unknown format %q, expected one of %s", c.Cfg.Explain, strings.Join(ExplainFormats, ", "))
+		}
+		transport = WithDryRunRoundTripper{r: transport, w: c.Stdout, format: c.Cfg.Explain, written: &c.dryRunWritten}
+	}
+
+	c.Http.Timeout = timeout
+	c.Http.Transport = newTransport(transport, retry)
+	return nil
+}
+
+// Whether requests changing something are written instead of sent, see WithDryRunRoundTripper
+func (c *Client) DryRun() bool {
+	return c.Cfg.DryRun || c.Cfg.Explain != ""
+}
+
+// Number of requests a dry run wrote instead of sending, see WithDryRunRoundTripper
+func (c *Client) DryRunWritten() int {
+	return c.dryRunWritten
+}
+
+// HTTP transport with the configured proxy, connect timeout and TLS settings
+func (c *Client) baseTransport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	connectTimeout, err := parseDuration(c.Cfg.ConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect_timeout: %w", err)
+	}
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+
+	if c.Cfg.Proxy != "" {
+		proxy, err := url.Parse(c.Cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy: unsupported scheme %q, expected http, https or socks5", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Cfg.Insecure}
+	if c.Cfg.CACert != "" {
+		pem, err := os.ReadFile(c.Cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("cacert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("cacert: no certificates found in %s", c.Cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.Cfg.ClientCert != "" || c.Cfg.ClientKey != "" {
+		if c.Cfg.ClientCert == "" || c.Cfg.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.Cfg.ClientCert, c.Cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client_cert: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if c.Cfg.Insecure {
+		logging.LogWarn("TLS certificate verification is disabled")
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// Parses a duration config value, empty values take the default
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
 func (c *Client) SetupJar() {
-	c.Http.Jar = cookiejar.NewPersistentJar(
+	if c.Cfg.Replay != "" { // the recorded cookies are redacted, keep them away from the real session
+		jar, _ := stdcookiejar.New(&stdcookiejar.Options{PublicSuffixList: publicsuffix.List})
+		c.Http.Jar = jar
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Cfg.CookieJarPath), 0o700); err != nil {
+		logging.LogDebug("Failed creating cookie jar directory: %s", err)
+	}
+	opts := []cookiejar.PersistentJarOption{
 		cookiejar.WithFilePath(c.Cfg.CookieJarPath),
 		cookiejar.WithAutoSync(true),
 		cookiejar.WithPublicSuffixList(publicsuffix.List),
-	)
+	}
+	if c.Cfg.Encrypt {
+		opts = append(opts, cookiejar.WithSerDer(&store.JarSerDer{Passphrase: c.Cfg.LoadPassphrase}))
+	}
+	c.Http.Jar = cookiejar.NewPersistentJar(opts...)
+}
+
+// Logs in with the configured credentials, the session cookie is kept in the jar
+func (c *Client) Login() error {
+	payload := map[string]string{
+		"username": c.Cfg.User,
+		"password": c.Cfg.Password,
+	}
+	return c.ExecuteJSON(http.MethodPost, "/login", payload, nil)
+}
+
+// Ends the current session
+func (c *Client) Logout() error {
+	_, err := c.Execute(Request{Method: http.MethodGet, Path: "/logout"})
+	return err
+}
+
+// Builds a multipart form with the logo in the "file" field
+func logoForm(filename string, r io.Reader) (io.Reader, string, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	// Detect MIME type by file extension
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream" // default if not detected
+	}
+	partHeaders := make(textproto.MIMEHeader)
+	partHeaders.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, "file", filepath.Base(filename)))
+	partHeaders.Set("Content-Type", mimeType)
+	part, err := w.CreatePart(partHeaders)
+	if err != nil {
+		return nil, "", fmt.Errorf("multipart.Writer.CreatePart: %w", err)
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, "", fmt.Errorf("io.Copy: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("multipart.Writer.Close: %w", err)
+	}
+	return body, w.FormDataContentType(), nil
 }
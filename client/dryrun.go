@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formats of the requests written by WithDryRunRoundTripper
+const (
+	ExplainHTTP = "http"
+	ExplainCurl = "curl"
+)
+
+var ExplainFormats = []string{ExplainHTTP, ExplainCurl}
+
+type WithDryRunRoundTripper struct {
+	r       http.RoundTripper
+	w       io.Writer
+	format  string // ExplainHTTP or ExplainCurl
+	written *int   // requests written so far
+}
+
+// Decorator writing the requests that change something to w instead of sending them, with secrets redacted.
+// Reads and logins are still sent so commands can look up what they change. The requests written
+// are answered with their own JSON body, or an empty object, as if the server accepted them
+func (drt WithDryRunRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !mutates(r) {
+		return drt.r.RoundTrip(r)
+	}
+	body, err := readBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if drt.format == ExplainCurl {
+		fmt.Fprintln(drt.w, curlCommand(r, body))
+	} else {
+		fmt.Fprint(drt.w, httpMessage(r, body))
+	}
+	*drt.written++
+
+	rspBody := []byte("{}")
+	if json.Valid(body) {
+		rspBody = body
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(rspBody)),
+		ContentLength: int64(len(rspBody)),
+		Request:       r,
+	}, nil
+}
+
+// Whether a request changes something on the server, logging in only creates a session
+func mutates(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !(r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/login"))
+}
+
+// Request as written on the wire, binary bodies are summarised
+func httpMessage(r *http.Request, body []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\n", r.Method, r.URL)
+	header := DefaultRedactor.Header(r.Header)
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[name] {
+			fmt.Fprintf(&sb, "%s: %s\n", name, v)
+		}
+	}
+	if len(body) > 0 {
+		sb.WriteString("\n")
+		if utf8.Valid(body) && !isMultipart(r) {
+			sb.Write(bytes.TrimRight(DefaultRedactor.Body(body), "\n"))
+			sb.WriteString("\n")
+		} else {
+			fmt.Fprintf(&sb, "<%d bytes of %s>\n", len(body), r.Header.Get("Content-Type"))
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// Equivalent curl command line, multipart forms are given as -F options with their original filenames
+func curlCommand(r *http.Request, body []byte) string {
+	args := []string{"curl", "-X", r.Method, shellQuote(r.URL.String())}
+	header := DefaultRedactor.Header(r.Header)
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		if isMultipart(r) && name == "Content-Type" { // set by curl along with the boundary
+			continue
+		}
+		for _, v := range header[name] {
+			args = append(args, "-H", shellQuote(name+": "+v))
+		}
+	}
+	switch {
+	case len(body) == 0:
+	case isMultipart(r):
+		args = append(args, formArgs(r, body)...)
+	default:
+		args = append(args, "--data-raw", shellQuote(strings.TrimRight(string(DefaultRedactor.Body(body)), "\n")))
+	}
+	return strings.Join(args, " ")
+}
+
+// -F options of the parts of a multipart form, files are referenced by the path they were read from, see Request.Upload
+func formArgs(r *http.Request, body []byte) []string {
+	_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	var args []string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return args
+		}
+		if part.FileName() == "" {
+			value, _ := io.ReadAll(part)
+			args = append(args, "-F", shellQuote(part.FormName()+"="+string(value)))
+			continue
+		}
+		arg := fmt.Sprintf("%s=@%s", part.FormName(), part.FileName())
+		if path := uploadOf(r); path != "" {
+			arg = fmt.Sprintf("%s=@%s", part.FormName(), path)
+			if filepath.Base(path) != part.FileName() { // e.g. read from /dev/stdin
+				arg += ";filename=" + part.FileName()
+			}
+		}
+		if ct := part.Header.Get("Content-Type"); ct != "" {
+			arg += ";type=" + ct
+		}
+		args = append(args, "-F", shellQuote(arg))
+	}
+}
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return strings.HasPrefix(mediaType, "multipart/")
+}
+
+// Quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
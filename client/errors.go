@@ -0,0 +1,46 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrUnauthorized = errors.New("Unauthorized!")
+	ErrForbidden    = errors.New("Forbidden!")
+	ErrNotFound     = errors.New("Not found!")
+	ErrConflict     = errors.New("Conflict!")
+	ErrModified     = errors.New("Modified since it was read!") // see UpdateIfUnmodified
+)
+
+// Error returned by the API, carries the status code and the raw response body
+type APIError struct {
+	StatusCode int
+	Message    string // message to be displayed, the response body by default
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Maps status codes to the sentinel errors, allowing e.g. errors.Is(err, ErrNotFound) on API errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrModified:
+		return e.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Fields the API returns that aren't declared by a record type, kept so they're sent back unchanged on updates
+type UnknownFields map[string]json.RawMessage
+
+func (m Member) MarshalJSON() ([]byte, error) {
+	type member Member // without the methods, so this isn't called again
+	return marshalWithUnknown(member(m), m.Unknown)
+}
+
+func (m *Member) UnmarshalJSON(data []byte) error {
+	type member Member
+	if err := json.Unmarshal(data, (*member)(m)); err != nil {
+		return err
+	}
+	unknown, err := unknownFields(data, reflect.TypeOf(*m))
+	m.Unknown = unknown
+	return err
+}
+
+func (p Project) MarshalJSON() ([]byte, error) {
+	type project Project
+	return marshalWithUnknown(project(p), p.Unknown)
+}
+
+func (p *Project) UnmarshalJSON(data []byte) error {
+	type project Project
+	if err := json.Unmarshal(data, (*project)(p)); err != nil {
+		return err
+	}
+	unknown, err := unknownFields(data, reflect.TypeOf(*p))
+	p.Unknown = unknown
+	return err
+}
+
+// Encodes the declared fields of v followed by the unknown ones, sorted, that don't clash with them
+func marshalWithUnknown(v any, unknown UnknownFields) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(unknown) == 0 {
+		return data, err
+	}
+	declared := jsonNames(reflect.TypeOf(v))
+	var buf bytes.Buffer
+	buf.Write(bytes.TrimSuffix(data, []byte("}")))
+	first := bytes.Equal(data, []byte("{}"))
+	for _, key := range slices.Sorted(maps.Keys(unknown)) {
+		if isDeclared(declared, key) {
+			continue
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(unknown[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Members of the JSON object data not declared by the fields of the struct type t, nil if there are none
+func unknownFields(data []byte, t reflect.Type) (UnknownFields, error) {
+	var all UnknownFields
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	declared := jsonNames(t)
+	for key := range all {
+		if isDeclared(declared, key) {
+			delete(all, key)
+		}
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+// JSON names of the encoded fields of a struct type
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+		case name == "":
+			names = append(names, f.Name)
+		default:
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Whether key names a declared field, matched case insensitively like encoding/json does
+func isDeclared(declared []string, key string) bool {
+	return slices.ContainsFunc(declared, func(name string) bool { return strings.EqualFold(name, key) })
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"username":"john","year":3,"links":{"git":"x"}}`, `{"username":"john","links":{"git":"x"},"year":3}`},
+		{`{"username":"john","name":"John"}`, `{"username":"john","name":"John"}`},
+		{`{"Username":"john","NAME":"John","year":null}`, `{"username":"john","name":"John","year":null}`},
+		{`{"year":3}`, `{"username":"","year":3}`},
+	}
+	for _, tt := range tests {
+		var m Member
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+			t.Fatalf("json.Unmarshal(%s): %s", tt.in, err)
+		}
+		got, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("json.Marshal: %s", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("round trip of %s = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	var p Project
+	if err := json.Unmarshal([]byte(`{"name":"web","repo":"hs/web"}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(&p); string(got) != `{"name":"web","repo":"hs/web"}` {
+		t.Errorf("round trip of project = %s", got)
+	}
+	p.Unknown = nil
+	if got, _ := json.Marshal(p); string(got) != `{"name":"web"}` {
+		t.Errorf("project without unknown fields = %s", got)
+	}
+}
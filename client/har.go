@@ -0,0 +1,280 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"hscli/logging"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HTTP Archive of the requests made, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+
+	mu sync.Mutex
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // total milliseconds, the sum of the timings
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for binary content, e.g. logos
+}
+
+// Milliseconds spent in each phase, -1 when it didn't happen, e.g. no DNS lookup on a reused connection
+type HARTimings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func NewHAR() *HAR {
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: ProgramName, Version: ProgramVersion},
+		Entries: []HAREntry{},
+	}}
+}
+
+type WithHARRoundTripper struct {
+	r        http.RoundTripper
+	path     string
+	har      *HAR
+	redactor Redactor
+}
+
+// Decorator to write every request and response, with timings, to a HAR file rewritten after each one
+func (hrt WithHARRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var t harTrace
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), t.clientTrace()))
+	t.start = time.Now()
+	rsp, err := hrt.r.RoundTrip(r)
+	if err != nil {
+		return rsp, err
+	}
+	t.firstByte = orNow(t.firstByte)
+	rspBody, err := readBody(&rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.end = time.Now()
+
+	entry := HAREntry{
+		StartedDateTime: t.start.Format(time.RFC3339Nano),
+		Request: HARRequest{
+			Method:      r.Method,
+			URL:         r.URL.String(),
+			HTTPVersion: r.Proto,
+			Cookies:     []HARCookie{},
+			Headers:     hrt.headers(r.Header),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: HARResponse{
+			Status:      rsp.StatusCode,
+			StatusText:  http.StatusText(rsp.StatusCode),
+			HTTPVersion: rsp.Proto,
+			Cookies:     []HARCookie{},
+			Headers:     hrt.headers(rsp.Header),
+			Content:     hrt.content(rsp.Header.Get("Content-Type"), rspBody),
+			RedirectURL: rsp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(rspBody),
+		},
+		Timings: t.timings(),
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	for name, values := range r.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: name, Value: v})
+		}
+	}
+	for _, c := range r.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, HARCookie{Name: c.Name, Value: hrt.redactor.Cookie(c.Name, c.Value)})
+	}
+	for _, c := range rsp.Cookies() {
+		cookie := HARCookie{Name: c.Name, Value: hrt.redactor.Cookie(c.Name, c.Value), Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(time.RFC3339)
+		}
+		entry.Response.Cookies = append(entry.Response.Cookies, cookie)
+	}
+	if len(reqBody) > 0 {
+		mimeType := r.Header.Get("Content-Type")
+		entry.Request.PostData = &HARPostData{MimeType: mimeType, Text: hrt.content(mimeType, reqBody).Text}
+	}
+	timings := entry.Timings
+	for _, d := range []float64{timings.DNS, timings.Connect, timings.Send, timings.Wait, timings.Receive} { // ssl is included in connect
+		entry.Time += max(d, 0)
+	}
+
+	hrt.har.mu.Lock()
+	defer hrt.har.mu.Unlock()
+	hrt.har.Log.Entries = append(hrt.har.Log.Entries, entry)
+	if err := writeJSONFile(hrt.path, hrt.har); err != nil {
+		logging.Error("Failed writing HAR", "path", hrt.path, "error", err)
+	}
+	return rsp, nil
+}
+
+func (hrt WithHARRoundTripper) headers(h http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+	for name, values := range hrt.redactor.Header(h) {
+		for _, v := range values {
+			headers = append(headers, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+// Redacted body, base64 encoded if not text
+func (hrt WithHARRoundTripper) content(contentType string, body []byte) HARContent {
+	c := HARContent{Size: len(body), MimeType: contentType}
+	if c.MimeType == "" {
+		c.MimeType = "application/octet-stream"
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.MimeType)
+	if utf8.Valid(body) && mediaType != "multipart/form-data" {
+		c.Text = string(hrt.redactor.Body(body))
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+	return c
+}
+
+// Instants of a request, zero if they didn't happen
+type harTrace struct {
+	start, dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, wrote, firstByte, end time.Time
+}
+
+func (t *harTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { t.connectStart = orNow(t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.connectDone = time.Now() },
+		TLSHandshakeStart:    func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.wrote = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+}
+
+func (t *harTrace) timings() HARTimings {
+	ms := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return float64(to.Sub(from).Microseconds()) / 1000
+	}
+	connectDone := t.connectDone
+	if !t.tlsDone.IsZero() {
+		connectDone = t.tlsDone
+	}
+	sendStart := t.start
+	for _, ts := range []time.Time{t.dnsDone, connectDone} {
+		if ts.After(sendStart) {
+			sendStart = ts
+		}
+	}
+	wrote := t.wrote
+	if wrote.IsZero() { // e.g. replayed from a cassette
+		wrote = sendStart
+	}
+	return HARTimings{
+		DNS:     ms(t.dnsStart, t.dnsDone),
+		Connect: ms(t.connectStart, connectDone),
+		SSL:     ms(t.tlsStart, t.tlsDone),
+		Send:    ms(sendStart, wrote),
+		Wait:    ms(wrote, t.firstByte),
+		Receive: ms(t.firstByte, t.end),
+	}
+}
+
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
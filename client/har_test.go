@@ -0,0 +1,71 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHAR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		if r.URL.Path == "/logo" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"username":"john"}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "session.har")
+	hc := http.Client{Transport: WithHARRoundTripper{r: http.DefaultTransport, path: path, har: NewHAR(), redactor: DefaultRedactor}}
+	rsp, err := hc.Post(srv.URL+"/login?next=home", "application/json", strings.NewReader(`{"username":"john","password":"hunter2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp, err = hc.Get(srv.URL + "/logo"); err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("version %q with %d entries, want 1.2 with 2", har.Log.Version, len(har.Log.Entries))
+	}
+
+	login := har.Log.Entries[0]
+	if got, want := login.Request.PostData.Text, `{"password":"<redacted>","username":"john"}`; got != want {
+		t.Errorf("postData = %s, want %s", got, want)
+	}
+	if got := login.Request.QueryString; len(got) != 1 || got[0] != (HARNameValue{Name: "next", Value: "home"}) {
+		t.Errorf("queryString = %v, want next=home", got)
+	}
+	if got := login.Response.Cookies; len(got) != 1 || got[0].Value != Redacted {
+		t.Errorf("response cookies = %v, want session redacted", got)
+	}
+	if got := login.Response.Content; got.Text != `{"username":"john"}` || got.Encoding != "" {
+		t.Errorf("content = %+v, want the JSON as text", got)
+	}
+	if login.Timings.Wait < 0 || login.Time <= 0 {
+		t.Errorf("timings = %+v with time %v, want them measured", login.Timings, login.Time)
+	}
+
+	logo := har.Log.Entries[1].Response.Content
+	if logo.Encoding != "base64" || logo.Text != "iVBOR/8=" || logo.Size != 5 {
+		t.Errorf("logo content = %+v, want it base64 encoded", logo)
+	}
+}
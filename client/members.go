@@ -0,0 +1,179 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+type Member struct {
+	Username     string   `json:"username"`
+	Name         string   `json:"name,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	IstID        string   `json:"ist_id,omitempty"`
+	MemberNumber int      `json:"member_number,omitempty"`
+	Course       string   `json:"course,omitempty"`
+	JoinDate     string   `json:"join_date,omitempty"`
+	ExitDate     string   `json:"exit_date,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Extra        string   `json:"extra,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+
+	Unknown UnknownFields `json:"-"` // other fields of the API, see UnknownFields
+}
+
+// Association between a member and a project
+type Membership struct {
+	EntryDate     string `json:"entry_date,omitempty"`
+	Contributions string `json:"contributions,omitempty"`
+	ExitDate      string `json:"exit_date,omitempty"`
+}
+
+type Tag struct {
+	Tag string `json:"tag"`
+}
+
+// Operations on the /members endpoints
+type MembersService struct {
+	c *Client
+}
+
+func (s *MembersService) List() ([]Member, error) {
+	var members []Member
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("members"), nil, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *MembersService) Get(username string) (*Member, error) {
+	var m Member
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("members", username), nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Creates the record in, a Member or a raw document sent as is
+func (s *MembersService) Create(in any) (*Member, error) {
+	var created Member
+	if err := s.c.ExecuteJSON(http.MethodPost, Path("members"), in, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Updates the fields of the record present in in, a Member or a raw document sent as is.
+// A Member omits its empty fields, send a raw document to clear them
+func (s *MembersService) Update(username string, in any) (*Member, error) {
+	var updated Member
+	if err := s.c.ExecuteJSON(http.MethodPut, Path("members", username), in, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Reads a record along with its version, see UpdateIfUnmodified
+func (s *MembersService) GetVersion(username string) (*Member, Version, error) {
+	var r Member
+	v, err := s.c.getVersion(Path("members", username), &r)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	return &r, v, nil
+}
+
+// Replaces the record with in, a Member or a raw document, failing with ErrModified if it's no longer at version v
+func (s *MembersService) UpdateIfUnmodified(username string, in any, v Version) (*Member, error) {
+	var updated, fresh Member
+	if err := s.c.putIfUnmodified(Path("members", username), in, &updated, &fresh, v); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Reads the record as the API returns it, fields the Member type doesn't declare included, along with its version
+func (s *MembersService) GetRawVersion(username string) (json.RawMessage, Version, error) {
+	var r json.RawMessage
+	v, err := s.c.getVersion(Path("members", username), &r)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	return r, v, nil
+}
+
+// Replaces the record with the document in like UpdateIfUnmodified, returning the updated record as the API returns it
+func (s *MembersService) UpdateRawIfUnmodified(username string, in any, v Version) (json.RawMessage, error) {
+	var updated, fresh json.RawMessage
+	if err := s.c.putIfUnmodified(Path("members", username), in, &updated, &fresh, v); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *MembersService) Delete(username string) error {
+	return s.c.ExecuteJSON(http.MethodDelete, Path("members", username), nil, nil)
+}
+
+// Projects the member takes part in
+func (s *MembersService) Projects(username string) ([]Project, error) {
+	var projects []Project
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("members", username, "projects"), nil, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// Adds the member to a project, ms may be nil
+func (s *MembersService) AddProject(username, project string, ms *Membership) error {
+	if ms == nil {
+		ms = &Membership{}
+	}
+	return s.c.ExecuteJSON(http.MethodPost, Path("members", username, project), ms, nil)
+}
+
+// Association of the member with a project, ErrNotFound if the member isn't in it
+func (s *MembersService) Membership(username, project string) (*Membership, error) {
+	var ms Membership
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("members", username, project), nil, &ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+// Removes the member from a project
+func (s *MembersService) RemoveProject(username, project string) error {
+	return s.c.ExecuteJSON(http.MethodDelete, Path("members", username, project), nil, nil)
+}
+
+func (s *MembersService) Tags(username string) ([]string, error) {
+	var tags []string
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("members", username, "tags"), nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *MembersService) AddTag(username, tag string) error {
+	return s.c.ExecuteJSON(http.MethodPut, Path("members", username, "tags"), Tag{Tag: tag}, nil)
+}
+
+func (s *MembersService) DeleteTag(username, tag string) error {
+	return s.c.ExecuteJSON(http.MethodDelete, Path("members", username, "tags"), Tag{Tag: tag}, nil)
+}
+
+// Raw logo image bytes
+func (s *MembersService) Logo(username string) ([]byte, error) {
+	return s.c.Execute(Request{Method: http.MethodGet, Path: Path("members", username, "logo")})
+}
+
+// Uploads the logo read from r as a multipart form, filename is used to detect the content type
+func (s *MembersService) UploadLogo(username, filename string, r io.Reader) error {
+	body, contentType, err := logoForm(filename, r)
+	if err != nil {
+		return err
+	}
+	_, err = s.c.Execute(Request{Method: http.MethodPost, Path: Path("members", username, "logo"), Body: body, ContentType: contentType, Upload: filename})
+	return err
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+type Project struct {
+	Name        string `json:"name"`
+	State       string `json:"state,omitempty"`
+	StartDate   string `json:"start_date,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Unknown UnknownFields `json:"-"` // other fields of the API, see UnknownFields
+}
+
+// Operations on the /projects endpoints
+type ProjectsService struct {
+	c *Client
+}
+
+func (s *ProjectsService) List() ([]Project, error) {
+	var projects []Project
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("projects"), nil, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (s *ProjectsService) Get(name string) (*Project, error) {
+	var p Project
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("projects", name), nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Creates the record in, a Project or a raw document sent as is
+func (s *ProjectsService) Create(in any) (*Project, error) {
+	var created Project
+	if err := s.c.ExecuteJSON(http.MethodPost, Path("projects"), in, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Updates the fields of the record present in in, a Project or a raw document sent as is.
+// A Project omits its empty fields, send a raw document to clear them
+func (s *ProjectsService) Update(name string, in any) (*Project, error) {
+	var updated Project
+	if err := s.c.ExecuteJSON(http.MethodPut, Path("projects", name), in, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Reads a record along with its version, see UpdateIfUnmodified
+func (s *ProjectsService) GetVersion(name string) (*Project, Version, error) {
+	var r Project
+	v, err := s.c.getVersion(Path("projects", name), &r)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	return &r, v, nil
+}
+
+// Replaces the record with in, a Project or a raw document, failing with ErrModified if it's no longer at version v
+func (s *ProjectsService) UpdateIfUnmodified(name string, in any, v Version) (*Project, error) {
+	var updated, fresh Project
+	if err := s.c.putIfUnmodified(Path("projects", name), in, &updated, &fresh, v); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Reads the record as the API returns it, fields the Project type doesn't declare included, along with its version
+func (s *ProjectsService) GetRawVersion(name string) (json.RawMessage, Version, error) {
+	var r json.RawMessage
+	v, err := s.c.getVersion(Path("projects", name), &r)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	return r, v, nil
+}
+
+// Replaces the record with the document in like UpdateIfUnmodified, returning the updated record as the API returns it
+func (s *ProjectsService) UpdateRawIfUnmodified(name string, in any, v Version) (json.RawMessage, error) {
+	var updated, fresh json.RawMessage
+	if err := s.c.putIfUnmodified(Path("projects", name), in, &updated, &fresh, v); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *ProjectsService) Delete(name string) error {
+	return s.c.ExecuteJSON(http.MethodDelete, Path("projects", name), nil, nil)
+}
+
+func (s *ProjectsService) Members(name string) ([]Member, error) {
+	var members []Member
+	if err := s.c.ExecuteJSON(http.MethodGet, Path("projects", name, "members"), nil, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Adds a member to the project, same as MembersService.AddProject
+func (s *ProjectsService) AddMember(name, username string, ms *Membership) error {
+	return s.c.Members.AddProject(username, name, ms)
+}
+
+// Raw logo image bytes
+func (s *ProjectsService) Logo(name string) ([]byte, error) {
+	return s.c.Execute(Request{Method: http.MethodGet, Path: Path("projects", name, "logo")})
+}
+
+// Uploads the logo read from r as a multipart form, filename is used to detect the content type
+func (s *ProjectsService) UploadLogo(name, filename string, r io.Reader) error {
+	body, contentType, err := logoForm(filename, r)
+	if err != nil {
+		return err
+	}
+	_, err = s.c.Execute(Request{Method: http.MethodPost, Path: Path("projects", name, "logo"), Body: body, ContentType: contentType, Upload: filename})
+	return err
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Replaces secrets in recorded traffic
+const Redacted = "<redacted>"
+
+// Redacts secrets from recorded traffic: values of headers and JSON keys with the given names, compared case insensitively.
+// Set-Cookie keeps the cookie names and attributes
+type Redactor struct {
+	Names []string
+}
+
+var DefaultRedactor = Redactor{Names: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "password", "passphrase", "token", "secret"}}
+
+// Parses a comma separated list of names, "none" disables redaction and an empty string is DefaultRedactor
+func ParseRedactor(s string) Redactor {
+	switch strings.TrimSpace(s) {
+	case "":
+		return DefaultRedactor
+	case "none":
+		return Redactor{}
+	}
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return Redactor{Names: names}
+}
+
+func (rd Redactor) redacts(name string) bool {
+	return slices.ContainsFunc(rd.Names, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// Copy of the headers with secrets redacted
+func (rd Redactor) Header(h http.Header) http.Header {
+	h = h.Clone()
+	for name, values := range h {
+		if !rd.redacts(name) {
+			continue
+		}
+		for i, v := range values {
+			if http.CanonicalHeaderKey(name) == "Set-Cookie" {
+				values[i] = redactSetCookie(v)
+			} else {
+				values[i] = Redacted
+			}
+		}
+	}
+	return h
+}
+
+// Value of a cookie, redacted if cookies, or this one by name, are secrets
+func (rd Redactor) Cookie(name, value string) string {
+	if rd.redacts("Cookie") || rd.redacts("Set-Cookie") || rd.redacts(name) {
+		return Redacted
+	}
+	return value
+}
+
+func redactSetCookie(v string) string {
+	cookie, attrs, _ := strings.Cut(v, ";")
+	name, _, _ := strings.Cut(cookie, "=")
+	v = name + "=" + Redacted
+	if attrs != "" {
+		v += ";" + attrs
+	}
+	return v
+}
+
+// Copy of a JSON body with secrets redacted, other bodies are returned as is
+func (rd Redactor) Body(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	if !rd.redactJSON(v) {
+		return body
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// Redacts secrets in place, returns whether any was found
+func (rd Redactor) redactJSON(v any) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if rd.redacts(k) {
+				v[k] = Redacted
+				found = true
+			} else if rd.redactJSON(e) {
+				found = true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if rd.redactJSON(e) {
+				found = true
+			}
+		}
+	}
+	return found
+}
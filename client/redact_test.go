@@ -0,0 +1,59 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"username":"john","password":"hunter2"}`, `{"password":"<redacted>","username":"john"}`},
+		{`[{"name":"x","Token":"abc"}]`, `[{"Token":"<redacted>","name":"x"}]`},
+		{`{"username":"john"}`, `{"username":"john"}`},
+		{"not json, password=hunter2", "not json, password=hunter2"},
+	}
+	for _, tt := range tests {
+		if got := string(DefaultRedactor.Body([]byte(tt.body))); got != tt.want {
+			t.Errorf("Body(%s) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Cookie", "session=abc")
+	h.Set("Content-Type", "application/json")
+	h.Add("Set-Cookie", "session=abc; Path=/; HttpOnly")
+	h.Add("Set-Cookie", "theme=dark")
+
+	got := DefaultRedactor.Header(h)
+	if v := got.Get("Cookie"); v != Redacted {
+		t.Errorf("Cookie = %q, want %q", v, Redacted)
+	}
+	if v := got.Get("Content-Type"); v != "application/json" {
+		t.Errorf("Content-Type = %q, want it untouched", v)
+	}
+	want := []string{"session=<redacted>; Path=/; HttpOnly", "theme=<redacted>"}
+	for i, v := range got.Values("Set-Cookie") {
+		if v != want[i] {
+			t.Errorf("Set-Cookie[%d] = %q, want %q", i, v, want[i])
+		}
+	}
+	if h.Get("Cookie") != "session=abc" {
+		t.Errorf("original header was modified")
+	}
+}
+
+func TestParseRedactor(t *testing.T) {
+	body := []byte(`{"password":"hunter2","email":"john@example.com"}`)
+	if got := string(ParseRedactor("none").Body(body)); got != string(body) {
+		t.Errorf("none redacted %s", got)
+	}
+	want := `{"email":"<redacted>","password":"hunter2"}`
+	if got := string(ParseRedactor(" email ,").Body(body)); got != want {
+		t.Errorf("email redacted %s, want %s", got, want)
+	}
+}
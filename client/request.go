@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Request to the API, executed by Client.Execute
+type Request struct {
+	Method      string
+	Path        string // path relative to the configured root, e.g. "/members/john"
+	Body        io.Reader
+	ContentType string
+	Accept      []int   // accepted status codes, defaults to 200, 201 and 204
+	IfMatch     string  // sent as the If-Match header when not empty
+	ETag        *string // receives the ETag header of the response when not nil
+	Upload      string  // path of the file sent in a multipart Body, referenced by dry runs instead of its base name
+}
+
+type uploadKey struct{}
+
+// Path of the file uploaded by a request, see Request.Upload
+func uploadOf(r *http.Request) string {
+	path, _ := r.Context().Value(uploadKey{}).(string)
+	return path
+}
+
+// Joins path segments escaping each one, e.g. Path("members", "john doe") returns "/members/john%20doe"
+func Path(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// Executes a request against the configured root and returns the response body.
+// Responses with a status not in req.Accept are returned as *APIError
+func (c *Client) Execute(req Request) ([]byte, error) {
+	endpoint, err := c.endpoint(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(req.Method, endpoint, req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest %s %s: %w", req.Method, endpoint, err)
+	}
+	if req.ContentType != "" {
+		httpReq.Header.Set("Content-Type", req.ContentType)
+	}
+	if req.IfMatch != "" {
+		httpReq.Header.Set("If-Match", req.IfMatch)
+	}
+	if req.Upload != "" {
+		httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), uploadKey{}, req.Upload))
+	}
+
+	rsp, err := c.Http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("http.Client.Do %s %s: %w", req.Method, endpoint, err)
+	}
+	defer rsp.Body.Close()
+	if req.ETag != nil {
+		*req.ETag = rsp.Header.Get("ETag")
+	}
+
+	rspData, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	accept := req.Accept
+	if len(accept) == 0 {
+		accept = []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}
+	}
+	if !slices.Contains(accept, rsp.StatusCode) {
+		return nil, &APIError{StatusCode: rsp.StatusCode, Message: errorMessage(rspData), Body: rspData}
+	}
+	return rspData, nil
+}
+
+// Joins the configured root with an API path.
+// Paths with . or .. segments, even escaped, are refused as joining would resolve them to other endpoints
+func (c *Client) endpoint(path string) (string, error) {
+	if c.Cfg.Root == "" {
+		return "", fmt.Errorf("no API root configured")
+	}
+	root, err := url.Parse(c.Cfg.Root)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse %s: %w", c.Cfg.Root, err)
+	}
+	rel, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse %s: %w", path, err)
+	}
+	for _, segment := range strings.Split(rel.EscapedPath(), "/") {
+		if unescaped, _ := url.PathUnescape(segment); unescaped == "." || unescaped == ".." {
+			return "", fmt.Errorf("invalid path %s: %q segments aren't allowed", path, unescaped)
+		}
+	}
+	u := root.JoinPath(rel.EscapedPath())
+	u.RawQuery = rel.RawQuery
+	return u.String(), nil
+}
+
+// Extracts a human readable message from an error response body.
+// Looks for the usual JSON error keys and falls back to the raw body
+func errorMessage(body []byte) string {
+	var obj map[string]any
+	if err := json.Unmarshal(body, &obj); err == nil {
+		for _, key := range []string{"message", "error", "description", "detail", "errors"} {
+			switch v := obj[key].(type) {
+			case string:
+				if v != "" {
+					return v
+				}
+			case nil:
+			default:
+				if encoded, err := json.Marshal(v); err == nil {
+					return string(encoded)
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// Executes a request encoding in as the JSON body (if not nil) and decoding the response into out (if not nil)
+func (c *Client) ExecuteJSON(method, path string, in any, out any, accept ...int) error {
+	req := Request{Method: method, Path: path, Accept: accept}
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		req.Body = bytes.NewReader(payload)
+		req.ContentType = "application/json"
+	}
+
+	rspData, err := c.Execute(req)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(rspData) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(rspData, out); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return nil
+}
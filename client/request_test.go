@@ -0,0 +1,29 @@
+package client
+
+import (
+	"hscli/config"
+	"testing"
+)
+
+func TestEndpoint(t *testing.T) {
+	c := &Client{Cfg: &config.Config{Root: "https://api.example.com/v1"}}
+	tests := []struct{ path, want string }{
+		{Path("members", "john"), "https://api.example.com/v1/members/john"},
+		{Path("members", "john doe", "tags"), "https://api.example.com/v1/members/john%20doe/tags"},
+		{Path("members", "a/b"), "https://api.example.com/v1/members/a%2Fb"},
+		{Path("members", "..."), "https://api.example.com/v1/members/..."},
+		{"/members?limit=1", "https://api.example.com/v1/members?limit=1"},
+	}
+	for _, tt := range tests {
+		got, err := c.endpoint(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("endpoint(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+
+	for _, path := range []string{Path("members", ".."), Path("members", "."), Path("..", "login"), "/members/%2e%2e/x", "/members/%2E"} {
+		if got, err := c.endpoint(path); err == nil {
+			t.Errorf("endpoint(%q) = %q, want an error", path, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/store"
+	"io/fs"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.nhat.io/cookiejar"
+)
+
+// Cookie of the session kept in the jar, the value is never exposed
+type Cookie struct {
+	Name     string     `json:"name"`
+	Domain   string     `json:"domain"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires"` // nil for cookies that only last the browser session
+	Secure   bool       `json:"secure"`
+	HttpOnly bool       `json:"http_only"`
+	Value    string     `json:"value"` // always redacted
+}
+
+// Cookies in the jar sent to the configured root
+func (c *Client) Session() ([]Cookie, error) {
+	entries, err := c.readJar()
+	if err != nil {
+		return nil, err
+	}
+	cookies := []Cookie{}
+	for _, domainEntries := range entries {
+		for _, e := range domainEntries {
+			if !c.matchesRoot(e) {
+				continue
+			}
+			cookie := Cookie{
+				Name:     e.Name,
+				Domain:   e.Domain,
+				Path:     e.Path,
+				Secure:   e.Secure,
+				HttpOnly: e.HttpOnly,
+				Value:    "<redacted>",
+			}
+			if e.Persistent {
+				expires := e.Expires
+				cookie.Expires = &expires
+			}
+			cookies = append(cookies, cookie)
+		}
+	}
+	slices.SortFunc(cookies, func(a, b Cookie) int { return strings.Compare(a.Name, b.Name) })
+	return cookies, nil
+}
+
+// Earliest expiry of the session cookies, false if there's no session or it doesn't expire
+func (c *Client) SessionExpiry() (time.Time, bool) {
+	cookies, err := c.Session()
+	if err != nil {
+		return time.Time{}, false
+	}
+	var earliest time.Time
+	for _, cookie := range cookies {
+		if cookie.Expires != nil && (earliest.IsZero() || cookie.Expires.Before(earliest)) {
+			earliest = *cookie.Expires
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// Removes the cookies of the configured root from the jar, keeping the ones of other servers.
+// Returns the number of cookies removed
+func (c *Client) ClearSession() (int, error) {
+	entries, err := c.readJar()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for domain, domainEntries := range entries {
+		for id, e := range domainEntries {
+			if c.matchesRoot(e) {
+				delete(domainEntries, id)
+				removed++
+			}
+		}
+		if len(domainEntries) == 0 {
+			delete(entries, domain)
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.writeJar(entries)
+}
+
+func (c *Client) matchesRoot(e cookiejar.Entry) bool {
+	u, err := url.Parse(c.Cfg.Root)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == e.Domain {
+		return true
+	}
+	return !e.HostOnly && strings.HasSuffix(host, "."+e.Domain)
+}
+
+// Reads the jar file with the same serializer used by the jar
+func (c *Client) readJar() (map[string]map[string]cookiejar.Entry, error) {
+	f, err := os.Open(c.Cfg.CookieJarPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]map[string]cookiejar.Entry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	// reads both plaintext and encrypted jars
+	serder := &store.JarSerDer{Passphrase: c.Cfg.LoadPassphrase}
+	entries, err := serder.Deserialize(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie jar %s: %w", c.Cfg.CookieJarPath, err)
+	}
+	if entries == nil {
+		entries = map[string]map[string]cookiejar.Entry{}
+	}
+	return entries, nil
+}
+
+func (c *Client) writeJar(entries map[string]map[string]cookiejar.Entry) error {
+	f, err := os.OpenFile(c.Cfg.CookieJarPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if c.Cfg.Encrypt {
+		serder := &store.JarSerDer{Passphrase: c.Cfg.LoadPassphrase}
+		return serder.Serialize(f, entries)
+	}
+	return json.NewEncoder(f).Encode(entries)
+}
@@ -1,8 +1,15 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"hscli/logging"
+	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -20,14 +27,139 @@ type WithLoggingRoundTripper struct {
 	r http.RoundTripper
 }
 
-// Decorator to log outgoing requests and incoming responses
+// Decorator to log outgoing requests and incoming responses, with the endpoint, status, duration and attempt as attributes
 func (lrt WithLoggingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	logging.LogDebug("Outgoing request %s %s", r.Method, r.URL)
+	attrs := []any{"method", r.Method, "endpoint", r.URL.Path, "attempt", attemptOf(r)}
+	logging.Debug("Outgoing request", attrs...)
 	start := time.Now()
 	rsp, err := lrt.r.RoundTrip(r)
+	attrs = append(attrs, "duration", time.Since(start))
 	if err != nil {
+		logging.Debug("Request failed", append(attrs, "error", err)...)
 		return rsp, err
 	}
-	logging.LogDebug("Incoming response %d, took %0.2fs", rsp.StatusCode, time.Since(start).Seconds())
+	logging.Debug("Incoming response", append(attrs, "status", rsp.StatusCode)...)
 	return rsp, err
 }
+
+type attemptKey struct{}
+
+// Attempt of a request made by WithRetryRoundTripper, starting at 1
+func attemptOf(r *http.Request) int {
+	if attempt, ok := r.Context().Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// Delay before a retry, attempt starts at 1 for the first retry
+type BackoffPolicy func(attempt int) time.Duration
+
+// Exponential backoff with full jitter, waits a random duration up to min(max, base*2^(attempt-1))
+func ExponentialBackoff(base, max time.Duration) BackoffPolicy {
+	return func(attempt int) time.Duration {
+		d := base << (attempt - 1)
+		if d <= 0 || d > max { // d <= 0 on overflow
+			d = max
+		}
+		return time.Duration(rand.Int64N(int64(d) + 1))
+	}
+}
+
+type WithRetryRoundTripper struct {
+	r           http.RoundTripper
+	MaxAttempts int           // total attempts, including the first one
+	Backoff     BackoffPolicy // delay between attempts, unless the server sends Retry-After
+	RetryAll    bool          // also retry non idempotent methods, e.g. POST
+}
+
+// Decorator to retry requests failing with connection errors, 429, 502, 503 or 504
+func (rrt WithRetryRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !rrt.RetryAll && !isIdempotent(r.Method) {
+		return rrt.r.RoundTrip(r)
+	}
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil { // body can't be sent again
+		return rrt.r.RoundTrip(r)
+	}
+
+	for attempt := 1; ; attempt++ {
+		r = r.WithContext(context.WithValue(r.Context(), attemptKey{}, attempt))
+		rsp, err := rrt.r.RoundTrip(r)
+		if attempt >= rrt.MaxAttempts || !shouldRetry(rsp, err) {
+			return rsp, err
+		}
+
+		delay := rrt.Backoff(attempt)
+		if rsp != nil {
+			if d, ok := retryAfter(rsp); ok {
+				delay = d
+			}
+			io.Copy(io.Discard, rsp.Body)
+			rsp.Body.Close()
+			logging.Debug("Retrying request", "method", r.Method, "endpoint", r.URL.Path, "status", rsp.StatusCode, "attempt", attempt+1, "max_attempts", rrt.MaxAttempts, "delay", delay)
+		} else {
+			logging.Debug("Retrying request", "method", r.Method, "endpoint", r.URL.Path, "error", err, "attempt", attempt+1, "max_attempts", rrt.MaxAttempts, "delay", delay)
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(r.Context())
+			r.Body = body
+		}
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(rsp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var netErr net.Error
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			(errors.As(err, &netErr) && netErr.Timeout())
+	}
+	switch rsp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Delay requested by the Retry-After header, either in seconds or as an HTTP date
+func retryAfter(rsp *http.Response) (time.Duration, bool) {
+	v := rsp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
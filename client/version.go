@@ -0,0 +1,78 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Version of a record as read from the API, to refuse updates when someone else changed it in between
+type Version struct {
+	ETag string // sent by the server, which checks it against If-Match itself
+	Hash string // of the record, checked against a fresh read when the server has no ETags
+}
+
+// Version of a record known only by its content, e.g. read from a file
+func HashVersion(record any) Version {
+	data, _ := json.Marshal(record)
+	return Version{Hash: hashJSON(data)}
+}
+
+// Hash of a JSON document, every field included, that doesn't depend on its formatting or the order of its keys
+func hashJSON(data []byte) string {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err == nil {
+		data, _ = json.Marshal(doc) // objects are encoded with sorted keys
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Reads the record at path into out and returns its version, hashing the response so fields out doesn't declare count too
+func (c *Client) getVersion(path string, out any) (Version, error) {
+	var etag string
+	data, err := c.Execute(Request{Method: http.MethodGet, Path: path, ETag: &etag})
+	if err != nil {
+		return Version{}, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return Version{}, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return Version{ETag: etag, Hash: hashJSON(data)}, nil
+}
+
+// Replaces the record at path with in, if it's still at version v, decoding the updated record into out, read again if the response is empty.
+// Returns ErrModified otherwise. Without an ETag the record is read again into fresh, a pointer to a value of the type of out,
+// leaving a short window in which a concurrent update isn't noticed
+func (c *Client) putIfUnmodified(path string, in, out, fresh any, v Version) error {
+	req := Request{Method: http.MethodPut, Path: path, ContentType: "application/json", IfMatch: v.ETag}
+	if v.ETag == "" {
+		current, err := c.getVersion(path, fresh)
+		if err != nil {
+			return err
+		}
+		if current.Hash != v.Hash {
+			return ErrModified
+		}
+	}
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	req.Body = bytes.NewReader(payload)
+	data, err := c.Execute(req)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 { // e.g. 204 No Content, the update succeeded
+		_, err := c.getVersion(path, out)
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	record := `{"username":"john","year":1}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			data, _ := io.ReadAll(r.Body)
+			record = string(data)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(record))
+	}))
+	defer srv.Close()
+	c := NewClient()
+	c.Cfg.Root = srv.URL
+
+	m, v, err := c.Members.GetVersion("john")
+	if err != nil {
+		t.Fatalf("GetVersion: %s", err)
+	}
+	if v != HashVersion(m) {
+		t.Errorf("version of the response %v differs from the version of the record %v", v, HashVersion(m))
+	}
+
+	record = `{"username":"john","year":2}` // changed by someone else, in a field Member doesn't declare
+	if _, err := c.Members.UpdateIfUnmodified("john", m, v); !errors.Is(err, ErrModified) {
+		t.Fatalf("UpdateIfUnmodified of a changed record: %v, want ErrModified", err)
+	}
+
+	m, v, err = c.Members.GetVersion("john")
+	if err != nil {
+		t.Fatalf("GetVersion: %s", err)
+	}
+	m.Name = "John"
+	updated, err := c.Members.UpdateIfUnmodified("john", m, v)
+	if err != nil {
+		t.Fatalf("UpdateIfUnmodified answered with 204: %s", err)
+	}
+	if updated.Name != "John" || string(updated.Unknown["year"]) != "2" {
+		t.Errorf("UpdateIfUnmodified = %+v, want the record read again", updated)
+	}
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"hscli/client"
+	"hscli/logging"
+	"hscli/manifest"
+)
+
+// Columns of the changes of plan and apply in the tabular output formats
+var ChangeColumns = []string{"action", "kind", "name", "detail"}
+
+// Shows the changes needed to bring the API to the state described by the manifests in args
+func Plan(prune bool) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		changes, err := plan(c, prune, args...)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(changes)
+	}
+}
+
+// Applies the manifests in args after showing the plan and asking for confirmation, unless yes is set.
+// The members and projects changed are recorded in the journal, command being shown for them
+func Apply(prune bool, yes bool, command string) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		changes, err := plan(c, prune, args...)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			fmt.Fprintf(Stderr, "No changes, the API matches the manifests\n")
+			return marshal(changes)
+		}
+
+		fmt.Fprintf(Stderr, "Plan:\n")
+		for _, ch := range changes {
+			fmt.Fprintf(Stderr, "  %s\n", ch)
+		}
+		if !yes {
+			if !Interactive() {
+				return nil, NewCommandError("Refusing to apply without confirmation, pass --yes", nil)
+			}
+			if !Confirm(fmt.Sprintf("Apply %d changes?", len(changes))) {
+				return nil, NewCommandError("Apply cancelled", nil)
+			}
+		}
+
+		applied, err := manifest.Apply(c, changes, journalChange(c, command))
+		logging.LogInfo("Applied %d of %d changes", len(applied), len(changes))
+		if err != nil {
+			return nil, requestError(err)
+		}
+		return marshal(applied)
+	}
+}
+
+func plan(c *client.Client, prune bool, paths ...string) ([]manifest.Change, error) {
+	if len(paths) == 0 {
+		return nil, NewCommandError("Missing manifest, pass one with -f", nil)
+	}
+	m, err := manifest.Load(paths...)
+	if err != nil {
+		return nil, NewCommandError(fmt.Sprintf("Invalid manifest: %s", err), err)
+	}
+	changes, err := manifest.Plan(c, m, prune)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if changes == nil {
+		changes = []manifest.Change{} // encoded as [] rather than null
+	}
+	return changes, nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"hscli/backup"
+	"hscli/client"
+	"hscli/logging"
+)
+
+// Backs up all members, projects, tags, memberships and logos to the directory or .tar.gz archive in args[0]
+func Backup(c *client.Client, args ...string) ([]byte, error) {
+	s, err := backup.Take(c)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	m, err := backup.Write(s, args[0], c.Cfg.Root)
+	if err != nil {
+		return nil, NewCommandError(fmt.Sprintf("Failed writing backup: %s", err), err)
+	}
+	logging.LogInfo("Backed up %d members and %d projects to %s", m.Members, m.Projects, args[0])
+	return marshal(m)
+}
+
+// Restores the backup in args[0] into the API, overwriting existing members and projects unless skipExisting is set.
+// The members and projects created or overwritten are recorded in the journal, command being shown for them
+func Restore(skipExisting bool, command string) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		s, m, err := backup.Read(args[0])
+		if err != nil {
+			return nil, NewCommandError(fmt.Sprintf("Invalid backup: %s", err), err)
+		}
+		logging.LogInfo("Restoring %d members and %d projects backed up from %s at %s",
+			m.Members, m.Projects, m.Root, m.CreatedAt.Format("2006-01-02 15:04:05"))
+
+		return restoreResult(backup.Restore(c, s, backup.RestoreOptions{SkipExisting: skipExisting, Journal: journalChange(c, command)}))
+	}
+}
+
+// Result of a restore. When it stopped part way the report of what it did is returned too, with ErrPartialFailure
+func restoreResult(r *backup.Report, err error) ([]byte, error) {
+	if err == nil {
+		return marshal(r)
+	}
+	if *r == (backup.Report{}) {
+		return nil, requestError(err)
+	}
+	data, merr := marshal(r)
+	if merr != nil {
+		return nil, merr
+	}
+	return data, NewCommandError(fmt.Sprintf("Restore stopped part way: %s", err), ErrPartialFailure)
+}
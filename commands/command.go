@@ -1,15 +1,31 @@
 package commands
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hscli/client"
+	"hscli/config"
 	"hscli/logging"
+	"hscli/output"
+	"hscli/store"
+	"io"
 	"os"
+	"sync"
 )
 
 type Command func(c *client.Client, args ...string) ([]byte, error)
 
+// Where commands read their input and write their results and messages, replaced when running in-process, e.g, in tests
+var (
+	Stdin  io.Reader = os.Stdin
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// Cause of the CommandError returned, along with a result, by commands that only did part of their work
+var ErrPartialFailure = errors.New("partial failure")
+
 type CommandError struct {
 	Cause   error  // the cause of the error
 	Message string // message to be displayed to the console if the error occurs
@@ -31,6 +47,53 @@ func (e CommandError) Error() string {
 	return e.Message
 }
 
+// Wraps an error returned by the client into a CommandError.
+// API errors keep the server message, anything else is reported as a failed request
+func requestError(err error) error {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return NewCommandError(apiErr.Error(), apiErr)
+	}
+	return NewCommandError("Failed requesting server", err)
+}
+
+// Standard input, read once by readFile as commands run again after a login would find it empty
+var stdin struct {
+	data []byte
+	err  error
+	once sync.Once
+}
+
+// Reads the file at path, /dev/stdin being read only once
+func readFile(path string) ([]byte, error) {
+	if path != "/dev/stdin" {
+		return os.ReadFile(path)
+	}
+	stdin.once.Do(func() { stdin.data, stdin.err = io.ReadAll(Stdin) })
+	return stdin.data, stdin.err
+}
+
+// Decodes the JSON file at path into v
+func readJSONFile(path string, v any) error {
+	data, err := readFile(path)
+	if err != nil {
+		return NewCommandError("Failed opening file", fmt.Errorf("readFile %s: %w", path, err))
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return NewCommandError("Invalid JSON in "+path, fmt.Errorf("json.Unmarshal: %w", err))
+	}
+	return nil
+}
+
+// Encodes a command result as JSON
+func marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, NewCommandError("Failed encoding result", fmt.Errorf("json.Marshal: %w", err))
+	}
+	return data, nil
+}
+
 func WithLoginRetry(cmd Command) Command {
 	return func(c *client.Client, args ...string) ([]byte, error) {
 		rsp, err := cmd(c, args...) // run command
@@ -53,6 +116,24 @@ func WithLoginRetry(cmd Command) Command {
 	}
 }
 
+// Saves the password encrypted after a successful command when encrypt is set, so later logins don't need it
+func WithCredentialStore(cmd Command) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		rsp, err := cmd(c, args...)
+		if err != nil || !c.Cfg.Encrypt {
+			return rsp, err
+		}
+		passphrase, err := c.Cfg.LoadPassphrase()
+		if err != nil {
+			return nil, NewCommandError("Failed reading store passphrase", err)
+		}
+		if err := store.SaveCredentials(config.CredentialsPath(c.Cfg.Profile), passphrase, c.Cfg.Password); err != nil {
+			return nil, NewCommandError("Failed saving credentials", err)
+		}
+		return rsp, nil
+	}
+}
+
 func DefaultLastArgumentToStdin(cmd Command) Command {
 	return func(c *client.Client, args ...string) ([]byte, error) {
 		if len(args) == 0 {
@@ -72,37 +153,65 @@ func DefaultLastArgumentToStdin(cmd Command) Command {
 }
 
 // Runs a command.
-// Returns 0 on success, 1 on an domain related errors such as (unauthorized, resource doesn't exist, etc) and 2 on generic errors (no connection, etc)
+// Returns 0 on success, 1 on an domain related errors such as (unauthorized, resource doesn't exist, etc), 2 on generic errors (no connection, etc)
+// and 3 when the command only partially succeeded, in which case its result is still written
 func RunCommand(c *client.Client, cmd Command, args ...string) int {
+	return RunCommandColumns(c, nil, cmd, args...)
+}
+
+// Runs a command like RunCommand, showing columns of its result in the tabular formats, e.g. MemberColumns
+func RunCommandColumns(c *client.Client, columns []string, cmd Command, args ...string) int {
 	r, err := cmd(c, args...)
+	if errors.Is(err, ErrPartialFailure) {
+		if code := render(c, r, columns); code != 0 {
+			return code
+		}
+		fmt.Fprintf(Stderr, "%s\n", err)
+		return 3
+	}
 	if err != nil {
 		var commandErr CommandError
 		if errors.As(err, &commandErr) {
-			if commandErr.Cause == nil || errors.Is(commandErr.Cause, client.ErrUnauthorized) { // business logic error
-				fmt.Fprintf(os.Stdout, "%s\n", err)
+			var apiErr *client.APIError
+			if commandErr.Cause == nil || errors.As(commandErr.Cause, &apiErr) { // business logic error
+				fmt.Fprintf(Stdout, "%s\n", err)
 				return 1
 			} else {
 				logging.LogDebug(commandErr.Cause.Error())
 			}
 		}
 
-		fmt.Fprintf(os.Stderr, "%s\n", err)
+		fmt.Fprintf(Stderr, "%s\n", err)
+		return 2
+	}
+	if c.DryRunWritten() > 0 { // the result would only echo the requests written
+		return 0
+	}
+	return render(c, r, columns)
+}
+
+// Writes a command result in the configured output format, returns the exit code
+func render(c *client.Client, r []byte, columns []string) int {
+	format, err := output.ParseFormat(c.Cfg.Output)
+	if err != nil {
+		fmt.Fprintf(Stderr, "%s\n", err)
+		return 2
+	}
+	if err := output.Render(Stdout, r, format, columns); err != nil {
+		logging.LogDebug("output.Render: %s", err)
+		fmt.Fprintf(Stderr, "Failed writing output\n")
 		return 2
 	}
-	fmt.Fprintf(os.Stdout, "%s\n", string(r))
 	return 0
 }
 
 // Example new command definition (can be anywhere in this package)
 // 	func Command1(c *client.Client, args ...string) ([]byte, error) {
 // 		// argument validation here
-// 		rsp, err := c.Http.Get(c.Cfg.Root + "/endopint")
+// 		members, err := c.Members.List()
 // 		if err != nil {
-// 			return nil, fmt.Errorf("failed doing something!")
-// 		}
-// 		if rsp.StatusCode == http.StatusUnauthorized {
-// 			return nil, client.ErrUnauthorized
+// 			return nil, requestError(err)
 // 		}
 //		// other logic here
-// 		return "result string (probably response json body)", nil
+// 		return marshal(members)
 // 	}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"hscli/client"
+	"hscli/config"
+)
+
+// Columns of config list-profiles in the tabular output formats
+var ProfileColumns = []string{"name", "root", "user", "current"}
+
+// Columns of config path in the tabular output formats
+var ConfigValueColumns = []string{"key", "value", "source"}
+
+type profileInfo struct {
+	Name    string `json:"name"`
+	Root    string `json:"root"`
+	User    string `json:"user"`
+	Current bool   `json:"current"`
+}
+
+type configValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Shows the configuration file in use and where each resolved value came from
+func ConfigPath(c *client.Client, args ...string) ([]byte, error) {
+	values := []configValue{
+		{Key: "file", Value: c.Cfg.File, Source: c.Cfg.Sources["file"]},
+		{Key: "profile", Value: c.Cfg.Profile, Source: c.Cfg.Sources["profile"]},
+	}
+	for _, key := range config.Keys() {
+		value := c.Cfg.Get(key)
+		if key == "password" && value != "" {
+			value = "<redacted>"
+		}
+		values = append(values, configValue{Key: key, Value: value, Source: c.Cfg.Sources[key]})
+	}
+	return marshal(values)
+}
+
+// Opens the configuration file in use
+func configFile(c *client.Client) (*config.File, error) {
+	if c.Cfg.File == "" {
+		return nil, NewCommandError("No configuration file in use, pass one with --config", nil)
+	}
+	f, err := config.ReadFile(c.Cfg.File)
+	if err != nil {
+		return nil, NewCommandError("Failed reading configuration file", err)
+	}
+	return f, nil
+}
+
+func ListProfiles(c *client.Client, args ...string) ([]byte, error) {
+	f, err := configFile(c)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []profileInfo{}
+	for _, name := range f.ProfileNames() {
+		p, _ := f.Resolve(name)
+		profiles = append(profiles, profileInfo{
+			Name:    name,
+			Root:    p.Root,
+			User:    p.User,
+			Current: name == c.Cfg.Profile,
+		})
+	}
+	return marshal(profiles)
+}
+
+func UseProfile(c *client.Client, args ...string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
+	}
+
+	if _, err := configFile(c); err != nil {
+		return nil, err
+	}
+	if err := config.SetCurrentProfile(c.Cfg.File, args[0]); err != nil {
+		return nil, NewCommandError(fmt.Sprintf("Failed switching to profile '%s'", args[0]), err)
+	}
+	return nil, nil
+}
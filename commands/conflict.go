@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/client"
+	"hscli/patch"
+	"strings"
+)
+
+// Reads and updates records of a kind, e.g. c.Members.GetVersion and c.Members.UpdateIfUnmodified
+type versionedStore[T any] struct {
+	kind string
+	get  func(name string) (*T, client.Version, error)
+	put  func(name string, in any, v client.Version) (*T, error)
+}
+
+func memberStore(c *client.Client) versionedStore[client.Member] {
+	return versionedStore[client.Member]{"member", c.Members.GetVersion, c.Members.UpdateIfUnmodified}
+}
+
+func projectStore(c *client.Client) versionedStore[client.Project] {
+	return versionedStore[client.Project]{"project", c.Projects.GetVersion, c.Projects.UpdateIfUnmodified}
+}
+
+// Reads and updates records of a kind as the API returns them, fields the client types don't declare included
+func rawMemberStore(c *client.Client) versionedStore[json.RawMessage] {
+	return versionedStore[json.RawMessage]{"member", rawGet(c.Members.GetRawVersion), rawPut(c.Members.UpdateRawIfUnmodified)}
+}
+
+func rawProjectStore(c *client.Client) versionedStore[json.RawMessage] {
+	return versionedStore[json.RawMessage]{"project", rawGet(c.Projects.GetRawVersion), rawPut(c.Projects.UpdateRawIfUnmodified)}
+}
+
+func rawGet(get func(string) (json.RawMessage, client.Version, error)) func(string) (*json.RawMessage, client.Version, error) {
+	return func(name string) (*json.RawMessage, client.Version, error) {
+		r, v, err := get(name)
+		return &r, v, err
+	}
+}
+
+func rawPut(put func(string, any, client.Version) (json.RawMessage, error)) func(string, any, client.Version) (*json.RawMessage, error) {
+	return func(name string, in any, v client.Version) (*json.RawMessage, error) {
+		r, err := put(name, in, v)
+		return &r, err
+	}
+}
+
+// Updates the record read as base at version v with mine, refusing to overwrite changes made by someone else in between.
+// Those changes are merged with mine when they touch other fields and merge is set or the user agrees, like a three-way merge
+func (s versionedStore[T]) update(name string, base *T, v client.Version, mine map[string]any, merge bool) ([]byte, error) {
+	updated, err := s.put(name, mine, v)
+	if err == nil {
+		return marshal(updated)
+	}
+	if !errors.Is(err, client.ErrModified) {
+		return nil, requestError(err)
+	}
+
+	theirs, v, err := s.get(name)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	var baseFields, theirFields map[string]any
+	if err := roundTrip(base, &baseFields); err != nil {
+		return nil, err
+	}
+	if err := roundTrip(theirs, &theirFields); err != nil {
+		return nil, err
+	}
+	merged, conflicts := patch.Merge3(baseFields, mine, theirFields)
+	changed := fmt.Sprintf("The %s %s changed since it was read", s.kind, name)
+	if len(conflicts) > 0 {
+		return nil, NewCommandError(fmt.Sprintf("%s, update refused as both changed %s", changed, strings.Join(conflicts, ", ")), nil)
+	}
+
+	current, _ := json.MarshalIndent(theirFields, "", "  ")
+	result, _ := json.MarshalIndent(merged, "", "  ")
+	fmt.Fprintf(Stderr, "%s, merging this update into it gives:\n%s", changed, diffLines(string(current), string(result)))
+	if !merge && !Confirm("Apply the merged update?") {
+		if Interactive() {
+			return nil, NewCommandError("Update cancelled", nil)
+		}
+		return nil, NewCommandError("Update refused, pass --merge to apply the merged update", nil)
+	}
+	updated, err = s.put(name, merged, v)
+	if errors.Is(err, client.ErrModified) {
+		return nil, NewCommandError(fmt.Sprintf("The %s %s changed again, update refused", s.kind, name), nil)
+	}
+	if err != nil {
+		return nil, requestError(err)
+	}
+	return marshal(updated)
+}
+
+// Updates a member from a file, if it didn't change since it was read into the base file, see versionedStore.update
+func UpdateMemberFrom(base string, merge bool) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		return updateFrom(rawMemberStore(c), base, merge, args...)
+	}
+}
+
+// Updates a project from a file, if it didn't change since it was read into the base file, see versionedStore.update
+func UpdateProjectFrom(base string, merge bool) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		return updateFrom(rawProjectStore(c), base, merge, args...)
+	}
+}
+
+// The base file is hashed as it was read, like the record the API returned, and the fields removed from it are sent empty
+func updateFrom(s versionedStore[json.RawMessage], base string, merge bool, args ...string) ([]byte, error) {
+	if len(args) != 2 {
+		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expected 2 got %d", len(args)), nil)
+	}
+
+	var baseRecord json.RawMessage
+	var baseFields, mine map[string]any
+	if err := readJSONFile(base, &baseRecord); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(base, &baseFields); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(args[1], &mine); err != nil {
+		return nil, err
+	}
+	return s.update(args[0], &baseRecord, client.HashVersion(baseRecord), patch.Replace(mine, baseFields), merge)
+}
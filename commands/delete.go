@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"hscli/client"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Refuses to run cmd when the configuration is protected, verb describing it in the error, e.g. "delete members"
+func Unprotected(verb string, cmd Command) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		if err := refuseProtected(c, verb); err != nil {
+			return nil, err
+		}
+		return cmd(c, args...)
+	}
+}
+
+// Error refusing to verb when the configuration of c is protected, nil otherwise
+func refuseProtected(c *client.Client, verb string) error {
+	if !c.Cfg.Protected {
+		return nil
+	}
+	if c.Cfg.Profile != "" {
+		return NewCommandError(fmt.Sprintf("Refusing to %s, the profile %s is protected", verb, c.Cfg.Profile), nil)
+	}
+	return NewCommandError(fmt.Sprintf("Refusing to %s, the configuration is protected", verb), nil)
+}
+
+// Shows what deleting the record of a kind named name loses, then asks to type the name, its field, to confirm.
+// Not asked when yes is set or in dry runs, refused when no terminal is attached
+func confirmDelete(c *client.Client, kind, field, name string, losses map[string][]string, yes bool) error {
+	var lost []string
+	for _, what := range slices.Sorted(maps.Keys(losses)) {
+		if len(losses[what]) > 0 {
+			lost = append(lost, fmt.Sprintf("  %s: %s\n", what, strings.Join(losses[what], ", ")))
+		}
+	}
+	if len(lost) > 0 {
+		fmt.Fprintf(Stderr, "Deleting the %s %s also removes its\n%s", kind, name, strings.Join(lost, ""))
+	}
+	if yes || c.DryRun() {
+		return nil
+	}
+
+	if !Interactive() {
+		return NewCommandError("Refusing to delete without confirmation, pass --yes", nil)
+	}
+	answer, err := Prompt(fmt.Sprintf("Type the %s %s to confirm: ", field, name))
+	if err != nil || answer != name {
+		return NewCommandError("Delete cancelled", nil)
+	}
+	return nil
+}
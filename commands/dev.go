@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"fmt"
+	"hscli/backup"
+	"hscli/client"
+	"hscli/fakeapi"
+	"hscli/logging"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Serves a fake API on addr until it fails. seed is an optional backup to load,
+// and a member username is added that logs in with password
+func ServeFake(addr, username, password, seed string, sessionTTL time.Duration, etags bool) error {
+	s := fakeapi.New()
+	s.SessionTTL = sessionTTL
+	s.ETags = etags
+	if seed != "" {
+		snapshot, _, err := backup.Read(seed)
+		if err != nil {
+			return fmt.Errorf("invalid seed: %w", err)
+		}
+		loadSnapshot(s, snapshot)
+		logging.LogInfo("Loaded %d members and %d projects from %s", len(snapshot.Members), len(snapshot.Projects), seed)
+	}
+	s.AddMember(client.Member{Username: username, Name: username}, password)
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logging.LogInfo("Serving a fake API on http://%s, log in as %s", l.Addr(), username)
+	return http.Serve(l, s)
+}
+
+func loadSnapshot(s *fakeapi.Server, snapshot *backup.Snapshot) {
+	for _, p := range snapshot.Projects {
+		s.AddProject(p)
+		if logo, ok := snapshot.ProjectLogos[p.Name]; ok {
+			s.SetLogo("projects", p.Name, http.DetectContentType(logo.Data), logo.Data)
+		}
+	}
+	for _, m := range snapshot.Members {
+		s.AddMember(m, "")
+		for _, tag := range snapshot.Tags[m.Username] {
+			s.AddTag(m.Username, tag)
+		}
+		for project, ms := range snapshot.Memberships[m.Username] {
+			s.AddMembership(m.Username, project, ms)
+		}
+		if logo, ok := snapshot.MemberLogos[m.Username]; ok {
+			s.SetLogo("members", m.Username, http.DetectContentType(logo.Data), logo.Data)
+		}
+	}
+}
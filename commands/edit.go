@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/client"
+	"hscli/patch"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func EditMember(c *client.Client, args ...string) ([]byte, error) {
+	return editUpdate(rawMemberStore(c), args...)
+}
+
+func EditProject(c *client.Client, args ...string) ([]byte, error) {
+	return editUpdate(rawProjectStore(c), args...)
+}
+
+// Edits the record as the API returns it, the fields removed or emptied in the editor are sent empty so the API clears them
+func editUpdate(s versionedStore[json.RawMessage], args ...string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
+	}
+
+	record, v, err := s.get(args[0])
+	if err != nil {
+		return nil, requestError(err)
+	}
+	var fields map[string]any
+	if err := roundTrip(record, &fields); err != nil {
+		return nil, err
+	}
+	mine, changed, err := edit(s.kind, fields)
+	if err != nil || !changed {
+		return nil, err
+	}
+	return s.update(args[0], record, v, patch.Replace(mine, fields), false)
+}
+
+// Opens a record as JSON in the user's editor until it's saved as valid JSON, like kubectl edit.
+// Invalid JSON is reopened with the error at the top, the diff is written to Stderr when the record changed
+func edit(kind string, record map[string]any) (map[string]any, bool, error) {
+	original, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, false, NewCommandError("Failed encoding "+kind, fmt.Errorf("json.MarshalIndent: %w", err))
+	}
+	f, err := os.CreateTemp("", "hscli-"+kind+"-*.json")
+	if err != nil {
+		return nil, false, NewCommandError("Failed creating temporary file", err)
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	header := fmt.Sprintf("# Edit the %s below, lines starting with '#' are ignored and an empty file cancels the edit.\n", kind)
+	content := append([]byte(header), original...)
+	for {
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			return nil, false, NewCommandError("Failed writing temporary file", err)
+		}
+		if err := runEditor(path); err != nil {
+			return nil, false, NewCommandError("Failed running editor", err)
+		}
+		saved, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, NewCommandError("Failed reading temporary file", err)
+		}
+		data := stripComments(saved)
+		if len(bytes.TrimSpace(data)) == 0 {
+			fmt.Fprintln(Stderr, "Edit cancelled, no changes made")
+			return nil, false, nil
+		}
+
+		var edited map[string]any
+		err = json.Unmarshal(data, &edited)
+		if err == nil && edited == nil {
+			err = errors.New("expected an object, got null")
+		}
+		if err != nil {
+			if bytes.Equal(saved, content) { // saved again without fixing the error
+				return nil, false, NewCommandError("Edit cancelled, invalid JSON: "+err.Error(), nil)
+			}
+			content = append([]byte(fmt.Sprintf("%s# Invalid JSON: %s\n", header, err)), data...)
+			continue
+		}
+
+		updated, err := json.MarshalIndent(edited, "", "  ")
+		if err != nil {
+			return nil, false, NewCommandError("Failed encoding "+kind, fmt.Errorf("json.MarshalIndent: %w", err))
+		}
+		if bytes.Equal(updated, original) {
+			fmt.Fprintln(Stderr, "Edit cancelled, no changes made")
+			return nil, false, nil
+		}
+		fmt.Fprint(Stderr, diffLines(string(original), string(updated)))
+		return edited, true, nil
+	}
+}
+
+// Runs $VISUAL or $EDITOR, vi if neither is set, on the file at path, with the streams of the commands.
+// The editor may have arguments, e.g. "code --wait"
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = Stdin, Stdout, Stderr
+	return cmd.Run()
+}
+
+// Drops the lines starting with '#', which can't be part of a JSON document
+func stripComments(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			buf.WriteString(line)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Line by line diff of a and b, with removed lines prefixed by "-", added ones by "+" and unchanged ones by " "
+func diffLines(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			fmt.Fprintf(&sb, " %s\n", x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&sb, "-%s\n", x[i])
+			i++
+		default:
+			fmt.Fprintf(&sb, "+%s\n", y[j])
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hscli/client"
+	"hscli/output"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	exportMembers  = "members"
+	exportProjects = "projects"
+)
+
+// Columns that can be joined into each record, by what is exported
+var exportJoins = map[string][]string{
+	exportMembers:  {"tags", "projects"},
+	exportProjects: {"members"},
+}
+
+// Exports all members or projects, as selected by args[0], to a CSV, JSONL or XLSX file written to Stdout as is, not in the output format.
+// columns selects and orders the columns, defaulting to every field and the joined columns. join adds columns fetched per record
+func Export(format string, columns []string, join []string) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		kind := args[0]
+		joins, ok := exportJoins[kind]
+		if !ok {
+			return nil, NewCommandError(fmt.Sprintf("Unknown export '%s', expected members or projects", kind), nil)
+		}
+		for _, j := range join {
+			if !slices.Contains(joins, j) {
+				return nil, NewCommandError(fmt.Sprintf("Cannot join '%s' into %s, expected one of %s", j, kind, strings.Join(joins, ", ")), nil)
+			}
+		}
+
+		var fields []string
+		var records []map[string]any
+		var err error
+		if kind == exportMembers {
+			fields = jsonFields(client.Member{})
+			records, err = exportRecords(c.Members.List)
+		} else {
+			fields = jsonFields(client.Project{})
+			records, err = exportRecords(c.Projects.List)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		available := slices.DeleteFunc(fields, func(f string) bool { return f == "password" })
+		for _, j := range join {
+			if !slices.Contains(available, j) {
+				available = append(available, j)
+			}
+		}
+		if len(columns) == 0 {
+			columns = available
+		}
+		for _, col := range columns {
+			if !slices.Contains(available, col) {
+				return nil, NewCommandError(fmt.Sprintf("Unknown column '%s', expected one of %s", col, strings.Join(available, ", ")), nil)
+			}
+		}
+
+		for _, r := range records {
+			for _, j := range join {
+				if r[j], err = exportJoin(c, kind, j, r); err != nil {
+					return nil, requestError(err)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := output.Export(&buf, records, columns, format); err != nil {
+			return nil, NewCommandError(fmt.Sprintf("Failed exporting: %s", err), err)
+		}
+		if _, err := Stdout.Write(buf.Bytes()); err != nil {
+			return nil, NewCommandError("Failed writing output", err)
+		}
+		return nil, nil
+	}
+}
+
+// Lists the records and decodes them as generic JSON objects
+func exportRecords[T any](list func() ([]T, error)) ([]map[string]any, error) {
+	items, err := list()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, NewCommandError("Failed encoding result", fmt.Errorf("json.Marshal: %w", err))
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, NewCommandError("Failed encoding result", fmt.Errorf("json.Unmarshal: %w", err))
+	}
+	return records, nil
+}
+
+// Values of a joined column for a record, as a list of names
+func exportJoin(c *client.Client, kind string, join string, r map[string]any) ([]any, error) {
+	names := []any{}
+	switch {
+	case kind == exportMembers && join == "tags":
+		tags, err := c.Members.Tags(r["username"].(string))
+		for _, t := range tags {
+			names = append(names, t)
+		}
+		return names, err
+	case kind == exportMembers && join == "projects":
+		projects, err := c.Members.Projects(r["username"].(string))
+		for _, p := range projects {
+			names = append(names, p.Name)
+		}
+		return names, err
+	default:
+		members, err := c.Projects.Members(r["name"].(string))
+		for _, m := range members {
+			names = append(names, m.Username)
+		}
+		return names, err
+	}
+}
+
+// JSON names of the fields of a struct, in declaration order
+func jsonFields(v any) []string {
+	var fields []string
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
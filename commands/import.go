@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"hscli/client"
+	"hscli/importer"
+	"hscli/logging"
+)
+
+const (
+	importCreated    = "created"
+	importIncomplete = "incomplete" // created, but some tags or projects couldn't be added
+	importFailed     = "failed"
+)
+
+// Columns of the outcomes of mimport in the tabular output formats
+var ImportColumns = []string{"line", "username", "status", "error"}
+
+// Outcome of importing a row
+type importResult struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Creates the members in the .csv or .jsonl file in args[0], adding their tags and projects.
+// mappingPath is an optional file mapping columns to fields. Stops at the first failing row unless continueOnError is set.
+// The members created are recorded in the journal, command being shown for them
+func ImportMembers(mappingPath string, continueOnError bool, command string) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		var mapping *importer.Mapping
+		if mappingPath != "" {
+			var err error
+			if mapping, err = importer.ReadMapping(mappingPath); err != nil {
+				return nil, NewCommandError(fmt.Sprintf("Invalid mapping: %s", err), err)
+			}
+		}
+		rows, err := importer.Read(args[0], mapping)
+		if err != nil {
+			return nil, NewCommandError(fmt.Sprintf("Invalid import file: %s", err), err)
+		}
+
+		results := []importResult{}
+		failed := 0
+		for _, row := range rows {
+			result := importRow(c, row, journalChange(c, command))
+			results = append(results, result)
+			if result.Status == importCreated {
+				continue
+			}
+			failed++
+			logging.LogError("Line %d: %s", row.Line, result.Error)
+			if !continueOnError {
+				break
+			}
+		}
+
+		data, err := marshal(results)
+		if err != nil {
+			return nil, err
+		}
+		summary := fmt.Sprintf("Imported %d of %d members, %d failed", len(results)-failed, len(rows), failed)
+		if len(results) < len(rows) {
+			summary += fmt.Sprintf(", stopped at line %d", results[len(results)-1].Line)
+		}
+		if failed > 0 {
+			return data, NewCommandError(summary, ErrPartialFailure)
+		}
+		logging.LogInfo(summary)
+		return data, nil
+	}
+}
+
+func importRow(c *client.Client, row importer.Row, journal func(kind, name string, change func() error) error) importResult {
+	result := importResult{Line: row.Line, Username: row.Member.Username, Status: importFailed}
+	if row.Err != nil {
+		result.Error = row.Err.Error()
+		return result
+	}
+
+	create := func() error { _, err := c.Members.Create(&row.Member); return err }
+	if err := withRelogin(c, func() error { return journal("member", row.Member.Username, create) }); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = importIncomplete
+	for _, tag := range row.Tags {
+		if err := withRelogin(c, func() error { return c.Members.AddTag(row.Member.Username, tag) }); err != nil {
+			result.Error = fmt.Sprintf("add tag %s: %s", tag, err)
+			return result
+		}
+	}
+	for _, project := range row.Projects {
+		if err := withRelogin(c, func() error { return c.Members.AddProject(row.Member.Username, project, nil) }); err != nil {
+			result.Error = fmt.Sprintf("add project %s: %s", project, err)
+			return result
+		}
+	}
+	result.Status = importCreated
+	return result
+}
+
+// Runs a request, logging in and running it again if the session expired.
+// Used by commands doing many requests, which can't be rerun as a whole by WithLoginRetry
+func withRelogin(c *client.Client, request func() error) error {
+	err := request()
+	if errors.Is(err, client.ErrUnauthorized) {
+		if _, err := Login(c); err != nil {
+			return err
+		}
+		return request()
+	}
+	return err
+}
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/backup"
+	"hscli/client"
+	"hscli/config"
+	"hscli/journal"
+	"hscli/logging"
+	"hscli/patch"
+	"io/fs"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Columns of journal list in the tabular output formats
+var JournalColumns = []string{"id", "time", "user", "profile", "command", "undone"}
+
+// Records the state of the member or project named by args[0], of the kind, in the journal before cmd changes it, so Undo can restore it.
+// command is the command line shown by JournalList. Nothing is recorded in dry runs or when cmd fails
+func Journaled(kind, command string, cmd Command) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		if len(args) == 0 || c.DryRun() {
+			return cmd(c, args...)
+		}
+		prior, err := take(c, kind, args[0])
+		if err != nil {
+			return nil, requestError(err)
+		}
+
+		rsp, err := cmd(c, args...)
+		if err != nil && !errors.Is(err, ErrPartialFailure) {
+			return rsp, err
+		}
+		if err := record(c, &journal.Entry{Command: command, Kind: kind, Name: args[0], Prior: prior}); err != nil {
+			logging.LogWarn("Failed recording the change in the journal, it can't be undone: %s", err)
+		}
+		return rsp, err
+	}
+}
+
+// Records a member or project created by cmd in the journal, so Undo can delete it. It's named by the field key of the result of cmd.
+// command is the command line shown by JournalList. Nothing is recorded in dry runs or when cmd fails
+func JournaledCreate(kind, key, command string, cmd Command) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		rsp, err := cmd(c, args...)
+		if err != nil || c.DryRun() {
+			return rsp, err
+		}
+		var created map[string]any
+		var name string
+		if err := json.Unmarshal(rsp, &created); err == nil {
+			name, _ = created[key].(string)
+		}
+		if name == "" {
+			logging.LogWarn("Failed reading the %s of the %s created, it can't be undone", key, kind)
+			return rsp, nil
+		}
+		if err := record(c, &journal.Entry{Command: command, Kind: kind, Name: name}); err != nil {
+			logging.LogWarn("Failed recording the change in the journal, it can't be undone: %s", err)
+		}
+		return rsp, nil
+	}
+}
+
+// Records the state of the member or project of the kind named name in the journal before change changes it, like Journaled
+// for commands changing several, see manifest.Apply and backup.RestoreOptions. None is recorded if change creates it
+func journalChange(c *client.Client, command string) func(kind, name string, change func() error) error {
+	return func(kind, name string, change func() error) error {
+		if c.DryRun() {
+			return change()
+		}
+		prior, err := take(c, kind, name)
+		if errors.Is(err, client.ErrNotFound) {
+			prior = nil // created by change, undone by deleting it
+		} else if err != nil {
+			return err
+		}
+		if err := change(); err != nil {
+			return err
+		}
+		if err := record(c, &journal.Entry{Command: command, Kind: kind, Name: name, Prior: prior}); err != nil {
+			logging.LogWarn("Failed recording the change of the %s %s in the journal, it can't be undone: %s", kind, name, err)
+		}
+		return nil
+	}
+}
+
+// Restores the member or project changed by the journal entry with the id in args[0], or the last one not undone yet.
+// The state replaced is recorded too, so an undo can be undone by its id
+func Undo(c *client.Client, args ...string) ([]byte, error) {
+	j := journal.Journal{Dir: config.JournalDir()}
+	var e *journal.Entry
+	var err error
+	if len(args) > 0 {
+		id, convErr := strconv.Atoi(args[0])
+		if convErr != nil {
+			return nil, NewCommandError(fmt.Sprintf("Invalid journal entry id %q", args[0]), nil)
+		}
+		e, err = j.Get(id)
+	} else {
+		e, err = j.Last()
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && len(args) > 0:
+		return nil, NewCommandError(fmt.Sprintf("No journal entry %s", args[0]), nil)
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, NewCommandError("Nothing to undo", nil)
+	case err != nil:
+		return nil, NewCommandError("Failed reading the journal", err)
+	case e.Undone:
+		return nil, NewCommandError(fmt.Sprintf("Journal entry %d was already undone", e.ID), nil)
+	case e.Root != c.Cfg.Root:
+		return nil, NewCommandError(fmt.Sprintf("Journal entry %d changed %s, select its profile to undo it", e.ID, e.Root), nil)
+	}
+
+	current, err := take(c, e.Kind, e.Name)
+	if errors.Is(err, client.ErrNotFound) {
+		current = nil // deleted since
+	} else if err != nil {
+		return nil, requestError(err)
+	}
+	if err := restore(c, e.Kind, e.Name, e.Prior, current); err != nil {
+		return nil, requestError(err)
+	}
+
+	e.Undone = true
+	if err := j.Save(e); err != nil {
+		return nil, NewCommandError("Failed updating the journal", err)
+	}
+	if err := record(c, &journal.Entry{Command: fmt.Sprintf("undo %d", e.ID), Kind: e.Kind, Name: e.Name, Undoes: e.ID, Prior: current}); err != nil {
+		logging.LogWarn("Failed recording the undo in the journal, it can't be undone: %s", err)
+	}
+	logging.LogInfo("Restored the %s %s as it was before %q, entry %d", e.Kind, e.Name, e.Command, e.ID)
+	return nil, nil
+}
+
+// Lists the changes recorded in the journal, oldest first, without the state they replaced
+func JournalList(c *client.Client, args ...string) ([]byte, error) {
+	entries, err := journal.Journal{Dir: config.JournalDir()}.List()
+	if err != nil {
+		return nil, NewCommandError("Failed reading the journal", err)
+	}
+	for _, e := range entries {
+		e.Prior = nil
+	}
+	return marshal(entries)
+}
+
+// Appends an entry to the journal, made by the configured user
+func record(c *client.Client, e *journal.Entry) error {
+	e.User, e.Profile, e.Root = c.Cfg.User, c.Cfg.Profile, c.Cfg.Root
+	return journal.Journal{Dir: config.JournalDir()}.Append(e)
+}
+
+// Snapshot of a single member or project, see backup.TakeMember and backup.TakeProject
+func take(c *client.Client, kind, name string) (*backup.Snapshot, error) {
+	if kind == "project" {
+		return backup.TakeProject(c, name)
+	}
+	return backup.TakeMember(c, name)
+}
+
+// Brings a member or project from the state of the snapshot current back to that of s, deleting it if s is nil.
+// current is nil if it doesn't exist
+func restore(c *client.Client, kind, name string, s, current *backup.Snapshot) error {
+	if kind == "project" {
+		return restoreProject(c, name, s, current)
+	}
+	return restoreMember(c, name, s, current)
+}
+
+// Restores the record, tags, memberships and logo of a member. Tags and memberships added since are removed
+func restoreMember(c *client.Client, username string, s, current *backup.Snapshot) error {
+	if s == nil {
+		return c.Members.Delete(username)
+	}
+	m := s.Members[0]
+	err := restoreRecord(memberStore(c), username, &m, func() error {
+		_, err := c.Members.Create(&m)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	tags, err := c.Members.Tags(username)
+	if err != nil {
+		return err
+	}
+	for _, tag := range s.Tags[username] {
+		if !slices.Contains(tags, tag) {
+			if err := c.Members.AddTag(username, tag); err != nil {
+				return err
+			}
+		}
+	}
+	for _, tag := range tags {
+		if !slices.Contains(s.Tags[username], tag) {
+			if err := c.Members.DeleteTag(username, tag); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := restoreMemberships(c, s, current); err != nil {
+		return err
+	}
+
+	if logo, ok := s.MemberLogos[username]; ok {
+		return c.Members.UploadLogo(username, logo.Filename, bytes.NewReader(logo.Data))
+	}
+	return nil
+}
+
+// Restores the record, memberships and logo of a project. Members added since are removed
+func restoreProject(c *client.Client, name string, s, current *backup.Snapshot) error {
+	if s == nil {
+		return c.Projects.Delete(name)
+	}
+	p := s.Projects[0]
+	err := restoreRecord(projectStore(c), name, &p, func() error {
+		_, err := c.Projects.Create(&p)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := restoreMemberships(c, s, current); err != nil {
+		return err
+	}
+
+	if logo, ok := s.ProjectLogos[name]; ok {
+		return c.Projects.UploadLogo(name, logo.Filename, bytes.NewReader(logo.Data))
+	}
+	return nil
+}
+
+type membershipKey struct{ username, project string }
+
+// Brings the memberships of the snapshot current back to those of s, both of the same member or project.
+// The API can't update a membership, one whose record changed since is removed and added back
+func restoreMemberships(c *client.Client, s, current *backup.Snapshot) error {
+	want, have := memberships(s), memberships(current)
+	for _, k := range sortedKeys(have) {
+		if ms, ok := want[k]; ok && ms == have[k] {
+			continue
+		}
+		if err := c.Members.RemoveProject(k.username, k.project); err != nil {
+			return err
+		}
+	}
+	for _, k := range sortedKeys(want) {
+		ms := want[k]
+		if current, ok := have[k]; ok && current == ms {
+			continue
+		}
+		if err := c.Members.AddProject(k.username, k.project, &ms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Memberships of the snapshot, none if it's nil
+func memberships(s *backup.Snapshot) map[membershipKey]client.Membership {
+	all := map[membershipKey]client.Membership{}
+	if s == nil {
+		return all
+	}
+	for username, projects := range s.Memberships {
+		for project, ms := range projects {
+			all[membershipKey{username, project}] = ms
+		}
+	}
+	return all
+}
+
+func sortedKeys(m map[membershipKey]client.Membership) []membershipKey {
+	return slices.SortedFunc(maps.Keys(m), func(a, b membershipKey) int {
+		return cmp.Or(strings.Compare(a.username, b.username), strings.Compare(a.project, b.project))
+	})
+}
+
+// Overwrites the record with prior, clearing the fields set since, or creates it if it was deleted
+func restoreRecord[T any](s versionedStore[T], name string, prior *T, create func() error) error {
+	current, v, err := s.get(name)
+	if errors.Is(err, client.ErrNotFound) {
+		return create()
+	}
+	if err != nil {
+		return err
+	}
+	var fields, currentFields map[string]any
+	if err := roundTrip(prior, &fields); err != nil {
+		return err
+	}
+	if err := roundTrip(current, &currentFields); err != nil {
+		return err
+	}
+	_, err = s.put(name, patch.Replace(fields, currentFields), v)
+	return err
+}
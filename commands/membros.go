@@ -3,72 +3,43 @@ package commands
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hscli/client"
-	"io"
-	"mime"
-	"mime/multipart"
-	"net/http"
-	"net/textproto"
-	"os"
-	"path/filepath"
+	"hscli/config"
 )
 
-func Login(c *client.Client, args ...string) ([]byte, error) {
-	var payload map[string]string = map[string]string{
-		"username": c.Cfg.User,
-		"password": c.Cfg.Password,
-	}
-	payloadJson, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("json.Marshal: %w", err)
-	}
+// Columns of members in the tabular output formats
+var MemberColumns = []string{"username", "name", "email", "course", "member_number", "tags"}
 
-	var endpoint string = c.Cfg.Root + "/login"
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payloadJson))
-	if err != nil {
-		return nil, fmt.Errorf("http.NewRequest POST %s: %w", endpoint, err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http.Do: %w", err)
-	}
-	defer rsp.Body.Close()
+func Login(c *client.Client, args ...string) ([]byte, error) {
+	if c.Cfg.Replay != "" { // the cassette has the response, and a redacted password
+		c.Cfg.Password = client.Redacted
+	} else if err := c.Cfg.LoadPassword(); err != nil {
+		if errors.Is(err, config.ErrNoPassword) {
+			return nil, NewCommandError("Missing 'password' config parameter", err)
+		}
+		return nil, NewCommandError("Failed reading password", err)
+	}
+	if err := c.Login(); err != nil {
+		return nil, requestError(err)
+	}
+	return nil, nil
+}
 
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
+func Logout(c *client.Client, args ...string) ([]byte, error) {
+	if err := c.Logout(); err != nil {
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return nil, nil
 }
 
 func GetMembers(c *client.Client, args ...string) ([]byte, error) {
-	var endpoint string = c.Cfg.Root + "/members"
-	rsp, err := c.Http.Get(endpoint)
+	members, err := c.Members.List()
 	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
+		return nil, requestError(err)
 	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return marshal(members)
 }
 
 func GetMemberByUsername(c *client.Client, args ...string) ([]byte, error) {
@@ -76,24 +47,11 @@ func GetMemberByUsername(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/members/" + args[0]
-	rsp, err := c.Http.Get(endpoint)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
+	member, _, err := c.Members.GetRawVersion(args[0]) // as the API returns it, to be the --base of an update
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return member, nil
 }
 
 func GetMemberProjects(c *client.Client, args ...string) ([]byte, error) {
@@ -101,24 +59,11 @@ func GetMemberProjects(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/members/" + args[0] + "/projects"
-	rsp, err := c.Http.Get(endpoint)
+	projects, err := c.Members.Projects(args[0])
 	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
+		return nil, requestError(err)
 	}
-	return rspData, nil
+	return marshal(projects)
 }
 
 func GetMemberLogo(c *client.Client, args ...string) ([]byte, error) {
@@ -126,24 +71,11 @@ func GetMemberLogo(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/members/" + args[0] + "/logo"
-	rsp, err := c.Http.Get(endpoint)
+	logo, err := c.Members.Logo(args[0])
 	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
+		return nil, requestError(err)
 	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return logo, nil
 }
 
 func CreateMember(c *client.Client, args ...string) ([]byte, error) {
@@ -151,31 +83,15 @@ func CreateMember(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
 	}
 
-	var filePath string = args[0]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
-
-	var endpoint string = c.Cfg.Root + "/members"
-	rsp, err := c.Http.Post(endpoint, "application/json", f)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Post %s: %w", endpoint, err))
+	var member json.RawMessage // sent as is
+	if err := readJSONFile(args[0], &member); err != nil {
+		return nil, err
 	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
+	created, err := c.Members.Create(member)
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return marshal(created)
 }
 
 func UpdateMember(c *client.Client, args ...string) ([]byte, error) {
@@ -183,36 +99,15 @@ func UpdateMember(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expected 2 got %d", len(args)), nil)
 	}
 
-	var filePath string = args[1]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
-
-	var endpoint string = c.Cfg.Root + "/members/" + args[0]
-	req, err := http.NewRequest("PUT", endpoint, f)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest PUT %s: %w", endpoint, err))
+	var member json.RawMessage // sent as is, so empty values clear fields
+	if err := readJSONFile(args[1], &member); err != nil {
+		return nil, err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	rsp, err := c.Http.Do(req)
+	updated, err := c.Members.Update(args[0], member)
 	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return marshal(updated)
 }
 
 func UpdateMemberLogo(c *client.Client, args ...string) ([]byte, error) {
@@ -221,88 +116,46 @@ func UpdateMemberLogo(c *client.Client, args ...string) ([]byte, error) {
 	}
 
 	var filePath string = args[1]
-	f, err := os.Open(filePath)
+	data, err := readFile(filePath)
 	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
+		return nil, NewCommandError("Failed opening file", fmt.Errorf("readFile %s: %w", filePath, err))
 	}
-	defer f.Close()
 
-	body := &bytes.Buffer{}
-	w := multipart.NewWriter(body)
-	// Detect MIME type by file extension
-	ext := filepath.Ext(filePath)
-	mimeType := mime.TypeByExtension(ext)
-	if mimeType == "" {
-		mimeType = "application/octet-stream" // default if not detected
-	}
-	// Create a form file part with custom content type
-	partHeaders := make(textproto.MIMEHeader)
-	partHeaders.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, "file", filepath.Base(filePath)))
-	partHeaders.Set("Content-Type", mimeType)
-	part, err := w.CreatePart(partHeaders)
-	if err != nil {
-		return nil, NewCommandError("Failed creating multipart form", fmt.Errorf("multipart.Writer.CreatePart: %w", err))
+	if err := c.Members.UploadLogo(args[0], filePath, bytes.NewReader(data)); err != nil {
+		return nil, requestError(err)
 	}
-	_, err = io.Copy(part, f)
-	if err != nil {
-		return nil, NewCommandError("Failed writing multipart form", fmt.Errorf("io.Copy: %w", err))
-	}
-	w.Close()
-	fmt.Println()
-
-	var endpoint string = c.Cfg.Root + "/members/" + args[0] + "/logo"
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body.Bytes()))
-	if err != nil {
-		return nil, NewCommandError("Failed creating server request", fmt.Errorf("http.NewRequest POST %s: %w", endpoint, err))
-	}
-	fmt.Println(w.FormDataContentType())
-	req.Header.Add("Content-Type", w.FormDataContentType())
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return nil, nil
 }
 
-func DeleteMember(c *client.Client, args ...string) ([]byte, error) {
-	if len(args) == 0 {
-		return nil, NewCommandError("Missing argument to command, expect 1 got 0", nil)
-	}
-
-	var endpoint string = c.Cfg.Root + "/members/" + args[0]
-	req, err := http.NewRequest("DELETE", endpoint, nil)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest DELETE %s: %w", endpoint, err))
-	}
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+// Deletes a member after showing its projects and tags, which are lost with it, and asking to type its username unless yes is set
+func DeleteMember(yes bool) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		if len(args) == 0 {
+			return nil, NewCommandError("Missing argument to command, expect 1 got 0", nil)
+		}
+
+		projects, err := c.Members.Projects(args[0])
+		if err != nil {
+			return nil, requestError(err)
+		}
+		tags, err := c.Members.Tags(args[0])
+		if err != nil {
+			return nil, requestError(err)
+		}
+		names := make([]string, len(projects))
+		for i, p := range projects {
+			names[i] = p.Name
+		}
+		losses := map[string][]string{"projects": names, "tags": tags}
+		if err := confirmDelete(c, "member", "username", args[0], losses, yes); err != nil {
+			return nil, err
+		}
+
+		if err := c.Members.Delete(args[0]); err != nil {
+			return nil, requestError(err)
+		}
+		return nil, nil
 	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
 }
 
 func AddProject(c *client.Client, args ...string) ([]byte, error) {
@@ -310,36 +163,14 @@ func AddProject(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expected 3 got %d", len(args)), nil)
 	}
 
-	var filePath string = args[2]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
-
-	var endpoint string = c.Cfg.Root + "/members/" + args[0] + "/" + args[1]
-	req, err := http.NewRequest("POST", endpoint, f)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest POST %s: %w", endpoint, err))
-	}
-	req.Header.Add("Content-Type", "application/json")
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
+	var membership client.Membership
+	if err := readJSONFile(args[2], &membership); err != nil {
+		return nil, err
 	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+	if err := c.Members.AddProject(args[0], args[1], &membership); err != nil {
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return nil, nil
 }
 
 func GetTags(c *client.Client, args ...string) ([]byte, error) {
@@ -347,24 +178,11 @@ func GetTags(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expected 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/members/" + args[0] + "/tags"
-	rsp, err := c.Http.Get(endpoint)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
+	tags, err := c.Members.Tags(args[0])
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return marshal(tags)
 }
 
 func AddTag(c *client.Client, args ...string) ([]byte, error) {
@@ -372,36 +190,14 @@ func AddTag(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expected 2 got %d", len(args)), nil)
 	}
 
-	var filePath string = args[1]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
-
-	var endpoint string = c.Cfg.Root + "/members/" + args[0] + "/tags"
-	req, err := http.NewRequest("PUT", endpoint, f)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest PUT %s: %w", endpoint, err))
-	}
-	req.Header.Add("Content-Type", "application/json")
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
+	var tag client.Tag
+	if err := readJSONFile(args[1], &tag); err != nil {
+		return nil, err
 	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+	if err := c.Members.AddTag(args[0], tag.Tag); err != nil {
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return nil, nil
 }
 
 func DeleteTag(c *client.Client, args ...string) ([]byte, error) {
@@ -409,34 +205,12 @@ func DeleteTag(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expected 2 got %d", len(args)), nil)
 	}
 
-	var filePath string = args[1]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
-
-	var endpoint string = c.Cfg.Root + "/members/" + args[2] + "/tags"
-	req, err := http.NewRequest("PUT", endpoint, f)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest DELETE %s: %w", endpoint, err))
-	}
-	req.Header.Add("Content-Type", "application/json")
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+	var tag client.Tag
+	if err := readJSONFile(args[1], &tag); err != nil {
+		return nil, err
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
+	if err := c.Members.DeleteTag(args[0], tag.Tag); err != nil {
+		return nil, requestError(err)
 	}
-	return rspData, nil
+	return nil, nil
 }
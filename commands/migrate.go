@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"hscli/backup"
+	"hscli/client"
+	"hscli/config"
+	"hscli/logging"
+	"strings"
+)
+
+type MigrateOptions struct {
+	From         string   // profile to read from
+	To           string   // profile to write to
+	SkipExisting bool     // leave members and projects already in the destination untouched
+	Anonymise    bool     // replace personal fields of members with placeholders
+	Usernames    []string // renames, as old=new
+}
+
+// Copies members, projects, tags, memberships and logos from one profile to another.
+// Each end gets its own client, with the profile's root, credentials and cookie jar as set in the file, see config.LoadProfile.
+// A dry run of the migration applies to both
+func Migrate(opts MigrateOptions) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		mapping, err := parseMapping(opts.Usernames)
+		if err != nil {
+			return nil, err
+		}
+		if c.Cfg.File == "" {
+			return nil, NewCommandError("No configuration file to read the profiles from", nil)
+		}
+		src, err := profileClient(c, opts.From)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := profileClient(c, opts.To)
+		if err != nil {
+			return nil, err
+		}
+		if err := refuseProtected(dst, "migrate into it"); err != nil {
+			return nil, err
+		}
+		if src.Cfg.Root == dst.Cfg.Root {
+			return nil, NewCommandError(fmt.Sprintf("Profiles '%s' and '%s' have the same root %s", opts.From, opts.To, src.Cfg.Root), nil)
+		}
+		if src.Cfg.CookieJarPath == dst.Cfg.CookieJarPath {
+			return nil, NewCommandError(fmt.Sprintf("Profiles '%s' and '%s' share the cookie jar %s, set one per profile", opts.From, opts.To, src.Cfg.CookieJarPath), nil)
+		}
+
+		var s *backup.Snapshot
+		_, err = WithLoginRetry(func(c *client.Client, args ...string) ([]byte, error) {
+			if s, err = backup.Take(c); err != nil {
+				return nil, requestError(err)
+			}
+			return nil, nil
+		})(src)
+		if err != nil {
+			return nil, err
+		}
+		logging.LogInfo("Read %d members and %d projects from %s", len(s.Members), len(s.Projects), src.Cfg.Root)
+
+		if err := s.RenameMembers(mapping); err != nil {
+			return nil, NewCommandError(fmt.Sprintf("Invalid username mapping: %s", err), err)
+		}
+		if opts.Anonymise {
+			s.Anonymise()
+		}
+
+		return WithLoginRetry(func(c *client.Client, args ...string) ([]byte, error) {
+			return restoreResult(backup.Restore(c, s, backup.RestoreOptions{SkipExisting: opts.SkipExisting}))
+		})(dst)
+	}
+}
+
+// Client configured from a profile of the config file of c alone, keeping the dry run settings of c
+func profileClient(c *client.Client, profile string) (*client.Client, error) {
+	pc := client.NewClient()
+	if err := config.LoadProfile(pc.Cfg, c.Cfg.File, c.Cfg.Sources["file"], profile); err != nil {
+		return nil, NewCommandError(fmt.Sprintf("Failed loading profile '%s': %s", profile, err), err)
+	}
+	pc.Cfg.DryRun, pc.Cfg.Explain = c.Cfg.DryRun, c.Cfg.Explain
+	if err := pc.Cfg.Validate(); err != nil {
+		return nil, NewCommandError(fmt.Sprintf("Invalid profile '%s': %s", profile, err), err)
+	}
+	if err := pc.SetupTransport(); err != nil {
+		return nil, NewCommandError(fmt.Sprintf("Invalid profile '%s': %s", profile, err), err)
+	}
+	pc.SetupJar()
+	return pc, nil
+}
+
+// Parses old=new pairs into a map
+func parseMapping(pairs []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, NewCommandError(fmt.Sprintf("Invalid username mapping '%s', expected old=new", pair), nil)
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
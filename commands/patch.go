@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"hscli/client"
+	"hscli/logging"
+	"hscli/patch"
+	"reflect"
+	"strings"
+)
+
+// Field level changes of mupdate and pupdate, applied to the current record in the order of the fields
+type PatchOptions struct {
+	MergePatch string   // file with a JSON Merge Patch, RFC 7386
+	JSONPatch  string   // file with a JSON Patch, RFC 6902
+	Set        []string // key=value, the value being JSON or else a string, e.g. member_number=42 or name=John Doe
+	Unset      []string // keys to clear
+	Merge      bool     // merge concurrent changes of other fields without asking, see versionedStore.update
+}
+
+func (o PatchOptions) Empty() bool {
+	return o.MergePatch == "" && o.JSONPatch == "" && len(o.Set) == 0 && len(o.Unset) == 0
+}
+
+// Updates a member with the patches of opts applied to its current record
+func PatchMember(opts PatchOptions) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		return patchUpdate(rawMemberStore(c), opts, args...)
+	}
+}
+
+// Updates a project with the patches of opts applied to its current record
+func PatchProject(opts PatchOptions) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		return patchUpdate(rawProjectStore(c), opts, args...)
+	}
+}
+
+func patchUpdate(s versionedStore[json.RawMessage], opts PatchOptions, args ...string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expected 1 got %d", len(args)), nil)
+	}
+
+	record, v, err := s.get(args[0])
+	if err != nil {
+		return nil, requestError(err)
+	}
+	fields, err := patchRecord(*record, opts)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return marshal(record)
+	}
+	return s.update(args[0], record, v, fields, opts.Merge)
+}
+
+// Applies the patches to a record as the API returned it, returns the document to send or nil if nothing changed.
+// The document is sent as patched, fields the client types don't declare included, except for the fields
+// the patches removed which are sent with an empty value, or the API would keep them
+func patchRecord(record json.RawMessage, opts PatchOptions) (map[string]any, error) {
+	var original map[string]any
+	var doc any // patched in place
+	if err := json.Unmarshal(record, &original); err != nil {
+		return nil, NewCommandError("The record isn't a JSON object", fmt.Errorf("json.Unmarshal: %w", err))
+	}
+	if err := json.Unmarshal(record, &doc); err != nil {
+		return nil, NewCommandError("The record isn't a JSON object", fmt.Errorf("json.Unmarshal: %w", err))
+	}
+
+	if opts.MergePatch != "" {
+		var mergePatch any
+		if err := readJSONFile(opts.MergePatch, &mergePatch); err != nil {
+			return nil, err
+		}
+		doc = patch.Merge(doc, mergePatch)
+	}
+	if opts.JSONPatch != "" {
+		ops, err := patch.ReadOperations(opts.JSONPatch)
+		if err != nil {
+			return nil, NewCommandError("Invalid JSON Patch in "+opts.JSONPatch, err)
+		}
+		if doc, err = patch.Apply(doc, ops); err != nil {
+			return nil, NewCommandError("Failed applying JSON Patch: "+err.Error(), nil)
+		}
+	}
+	fields, ok := doc.(map[string]any)
+	if !ok {
+		return nil, NewCommandError("The patched record isn't a JSON object", nil)
+	}
+	for _, kv := range opts.Set {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, NewCommandError(fmt.Sprintf("Invalid --set %q, expected key=value", kv), nil)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		fields[key] = v
+	}
+	for _, key := range opts.Unset {
+		delete(fields, key)
+	}
+
+	patch.Replace(fields, original)
+	if reflect.DeepEqual(fields, original) {
+		logging.LogInfo("No changes to apply")
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// Decodes the JSON encoding of v into out
+func roundTrip(v any, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return NewCommandError("Failed encoding record", fmt.Errorf("json.Marshal: %w", err))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return NewCommandError("Failed decoding record", fmt.Errorf("json.Unmarshal: %w", err))
+	}
+	return nil
+}
@@ -1,32 +1,20 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"hscli/client"
-	"io"
-	"net/http"
-	"os"
 )
 
-func GetProjects(c *client.Client, args ...string) ([]byte, error) {
-	var endpoint string = c.Cfg.Root + "/projects"
-	rsp, err := c.Http.Get(endpoint)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
+// Columns of projects in the tabular output formats
+var ProjectColumns = []string{"name", "state", "start_date"}
 
-	rspData, err := io.ReadAll(rsp.Body)
+func GetProjects(c *client.Client, args ...string) ([]byte, error) {
+	projects, err := c.Projects.List()
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
+		return nil, requestError(err)
 	}
-	return rspData, nil
+	return marshal(projects)
 }
 
 func GetProjectByID(c *client.Client, args ...string) ([]byte, error) {
@@ -34,24 +22,11 @@ func GetProjectByID(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expecteded 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/projects/" + args[0]
-	rsp, err := c.Http.Get(endpoint)
+	project, _, err := c.Projects.GetRawVersion(args[0]) // as the API returns it, to be the --base of an update
 	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
+		return nil, requestError(err)
 	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return project, nil
 }
 
 func GetProjectMembers(c *client.Client, args ...string) ([]byte, error) {
@@ -59,55 +34,38 @@ func GetProjectMembers(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expecteded 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/projects/" + args[0] + "/members"
-	rsp, err := c.Http.Get(endpoint)
+	members, err := c.Projects.Members(args[0])
 	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
+		return nil, requestError(err)
 	}
-	defer rsp.Body.Close()
+	return marshal(members)
+}
 
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+func AddMember(c *client.Client, args ...string) ([]byte, error) {
+	if len(args) < 2 {
+		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expecteded 2 got %d", len(args)), nil)
 	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
+
+	if err := c.Projects.AddMember(args[0], args[1], nil); err != nil {
+		return nil, requestError(err)
 	}
-	return rspData, nil
+	return nil, nil
 }
 
 func CreateProject(c *client.Client, args ...string) ([]byte, error) {
 	if len(args) == 0 {
 		return nil, NewCommandError("Missing argument to command, expecteded 1 got 0", nil)
 	}
-	var filePath string = args[0]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
 
-	var endpoint string = c.Cfg.Root + "/projects"
-	rsp, err := c.Http.Post(endpoint, "application/json", f)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Post %s: %w", endpoint, err))
+	var project json.RawMessage // sent as is
+	if err := readJSONFile(args[0], &project); err != nil {
+		return nil, err
 	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
+	created, err := c.Projects.Create(project)
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return marshal(created)
 }
 
 func UpdateProject(c *client.Client, args ...string) ([]byte, error) {
@@ -115,65 +73,42 @@ func UpdateProject(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError(fmt.Sprintf("Missing argument to command, expecteded 2 got %d", len(args)), nil)
 	}
 
-	var filePath string = args[1]
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, NewCommandError("Failed opening file", fmt.Errorf("os.Open %s: %w", filePath, err))
-	}
-	defer f.Close()
-
-	var endpoint string = c.Cfg.Root + "/projects/" + args[0]
-	req, err := http.NewRequest("PUT", endpoint, f)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest PUT %s: %w", endpoint, err))
-	}
-	req.Header.Add("Content-Type", "application/json")
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
+	var project json.RawMessage // sent as is, so empty values clear fields
+	if err := readJSONFile(args[1], &project); err != nil {
+		return nil, err
 	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
+	updated, err := c.Projects.Update(args[0], project)
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+		return nil, requestError(err)
 	}
-	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
+	return marshal(updated)
 }
 
-func DeleteProject(c *client.Client, args ...string) ([]byte, error) {
-	if len(args) == 0 {
-		return nil, NewCommandError("Missing argument to command, expecteded 1 got 0", nil)
-	}
-
-	var endpoint string = c.Cfg.Root + "/projects/" + args[0]
-	req, err := http.NewRequest("DELETE", endpoint, nil)
-	if err != nil {
-		return nil, NewCommandError("Failed creating request server", fmt.Errorf("http.NewRequest DELETE %s: %w", endpoint, err))
-	}
-	rsp, err := c.Http.Do(req)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Client.Do %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
+// Deletes a project after showing its members, who lose their membership, and asking to type its name unless yes is set
+func DeleteProject(yes bool) Command {
+	return func(c *client.Client, args ...string) ([]byte, error) {
+		if len(args) == 0 {
+			return nil, NewCommandError("Missing argument to command, expecteded 1 got 0", nil)
+		}
+
+		members, err := c.Projects.Members(args[0])
+		if err != nil {
+			return nil, requestError(err)
+		}
+		usernames := make([]string, len(members))
+		for i, m := range members {
+			usernames[i] = m.Username
+		}
+		losses := map[string][]string{"members": usernames}
+		if err := confirmDelete(c, "project", "name", args[0], losses, yes); err != nil {
+			return nil, err
+		}
+
+		if err := c.Projects.Delete(args[0]); err != nil {
+			return nil, requestError(err)
+		}
+		return nil, nil
 	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
-	}
-	return rspData, nil
 }
 
 func GetProjectLogo(c *client.Client, args ...string) ([]byte, error) {
@@ -181,22 +116,9 @@ func GetProjectLogo(c *client.Client, args ...string) ([]byte, error) {
 		return nil, NewCommandError("Missing argument to command, expecteded 1 got 0", nil)
 	}
 
-	var endpoint string = c.Cfg.Root + "/projects/" + args[0] + "/logo"
-	rsp, err := c.Http.Get(endpoint)
-	if err != nil {
-		return nil, NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", endpoint, err))
-	}
-	defer rsp.Body.Close()
-
-	rspData, err := io.ReadAll(rsp.Body)
+	logo, err := c.Projects.Logo(args[0])
 	if err != nil {
-		return nil, NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
-	}
-	if rsp.StatusCode == http.StatusUnauthorized {
-		return rspData, NewCommandError(string(rspData), client.ErrUnauthorized)
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, NewCommandError(string(rspData), nil)
+		return nil, requestError(err)
 	}
-	return rspData, nil
+	return logo, nil
 }
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Whether the user can be prompted, i.e. Stdin is a terminal
+func Interactive() bool {
+	f, ok := Stdin.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// Asks a question on the terminal and returns the answer, trimmed
+func Prompt(question string) (string, error) {
+	fmt.Fprint(Stderr, question)
+	answer, err := bufio.NewReader(Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// Asks a yes/no question, false if no terminal is attached
+func Confirm(question string) bool {
+	if !Interactive() {
+		return false
+	}
+	answer, err := Prompt(question + " [y/N] ")
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
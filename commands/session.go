@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"hscli/client"
+	"hscli/logging"
+	"time"
+)
+
+const defaultSessionWarning = 10 * time.Minute
+
+// Logs a warning if the session in the jar expires within the configured session_warning duration
+func WarnSessionExpiry(c *client.Client) {
+	warning := defaultSessionWarning
+	if c.Cfg.SessionWarning != "" {
+		d, err := time.ParseDuration(c.Cfg.SessionWarning)
+		if err != nil {
+			logging.LogDebug("Invalid session_warning %q: %s", c.Cfg.SessionWarning, err)
+			return
+		}
+		warning = d
+	}
+	if warning <= 0 {
+		return
+	}
+
+	expires, ok := c.SessionExpiry()
+	if !ok {
+		return
+	}
+	left := time.Until(expires)
+	if left > 0 && left < warning {
+		logging.LogWarn("Session expires in %s, run login to renew it", left.Round(time.Second))
+	}
+}
+
+// Shows the record of the configured user
+func WhoAmI(c *client.Client, args ...string) ([]byte, error) {
+	member, err := c.Members.Get(c.Cfg.User)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	return marshal(member)
+}
+
+// Columns of session show in the tabular output formats
+var CookieColumns = []string{"name", "domain", "path", "expires", "secure", "http_only"}
+
+// Shows the cookies in the jar for the configured root, with their values redacted
+func ShowSession(c *client.Client, args ...string) ([]byte, error) {
+	cookies, err := c.Session()
+	if err != nil {
+		return nil, NewCommandError("Failed reading cookie jar", err)
+	}
+	return marshal(cookies)
+}
+
+// Removes the cookies of the configured root from the jar
+func ClearSession(c *client.Client, args ...string) ([]byte, error) {
+	if _, err := c.ClearSession(); err != nil {
+		return nil, NewCommandError("Failed clearing session", err)
+	}
+	return nil, nil
+}
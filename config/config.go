@@ -2,8 +2,12 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"hscli/logging"
 	"io/fs"
+	"os"
+	"reflect"
+	"strings"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/urfave/cli/v2"
@@ -12,49 +16,273 @@ import (
 const EX_CONFIG = 78 // https://stackoverflow.com/questions/1101957/are-there-any-standard-exit-status-codes-in-linux
 
 type Config struct {
-	Root          string `yaml:"root"      env:"HS_ROOT" env-default:""`
-	User          string `yaml:"user"      env:"HS_USER" env-default:""`
-	Password      string `yaml:"password"  env:"HS_PASSWORD" env-default:""`
-	CookieJarPath string `yaml:"cookiejar" env:"HS_COOKIEJAR" env-default:""`
+	Root          string `yaml:"root,omitempty"      env:"HS_ROOT" env-default:""`
+	User          string `yaml:"user,omitempty"      env:"HS_USER" env-default:""`
+	Password      string `yaml:"password,omitempty"  env:"HS_PASSWORD" env-default:""`
+	CookieJarPath string `yaml:"cookiejar,omitempty" env:"HS_COOKIEJAR" env-default:""`
+	Output        string `yaml:"output,omitempty"    env:"HS_OUTPUT" env-default:""`
+
+	// Connection settings, see client.SetupTransport
+	Timeout        string `yaml:"timeout,omitempty"         env:"HS_TIMEOUT" env-default:""`         // whole request, including retries, defaults to 100s
+	ConnectTimeout string `yaml:"connect_timeout,omitempty" env:"HS_CONNECT_TIMEOUT" env-default:""` // establishing the connection, defaults to 30s
+	Proxy          string `yaml:"proxy,omitempty"           env:"HS_PROXY" env-default:""`           // http(s):// or socks5:// URL, defaults to the HTTP_PROXY/HTTPS_PROXY environment
+	CACert         string `yaml:"cacert,omitempty"          env:"HS_CACERT" env-default:""`          // PEM file with extra CAs to trust
+	ClientCert     string `yaml:"client_cert,omitempty"     env:"HS_CLIENT_CERT" env-default:""`     // PEM certificate for mTLS
+	ClientKey      string `yaml:"client_key,omitempty"      env:"HS_CLIENT_KEY" env-default:""`      // PEM key for mTLS
+	Insecure       bool   `yaml:"insecure,omitempty"        env:"HS_INSECURE" env-default:"false"`   // skip TLS certificate verification
+
+	// Retries of failed requests, see client.WithRetryRoundTripper
+	MaxAttempts     int    `yaml:"max_attempts,omitempty"      env:"HS_MAX_ATTEMPTS" env-default:"0"`     // total attempts per request, defaults to 3, 1 disables retries
+	RetryBackoff    string `yaml:"retry_backoff,omitempty"     env:"HS_RETRY_BACKOFF" env-default:""`     // base delay, defaults to 500ms
+	RetryMaxBackoff string `yaml:"retry_max_backoff,omitempty" env:"HS_RETRY_MAX_BACKOFF" env-default:""` // maximum delay, defaults to 30s
+	RetryPost       bool   `yaml:"retry_post,omitempty"        env:"HS_RETRY_POST" env-default:"false"`   // also retry non idempotent requests
+
+	// Cassettes of HTTP traffic, see client.WithRecordingRoundTripper and client.WithReplayRoundTripper
+	Record string `yaml:"record,omitempty" env:"HS_RECORD" env-default:""` // record requests and responses to this file
+	Replay string `yaml:"replay,omitempty" env:"HS_REPLAY" env-default:""` // answer requests from this file, without any network
+
+	// HTTP Archive of the session, see client.WithHARRoundTripper
+	HAR       string `yaml:"har,omitempty"        env:"HS_HAR" env-default:""`        // write every request and response to this file
+	HARRedact string `yaml:"har_redact,omitempty" env:"HS_HAR_REDACT" env-default:""` // headers, cookies and JSON fields to redact, see client.ParseRedactor
+
+	// Requests written instead of sent, see client.WithDryRunRoundTripper
+	DryRun  bool   `yaml:"dry_run,omitempty" env:"HS_DRY_RUN" env-default:"false"` // write the requests changing something instead of sending them
+	Explain string `yaml:"explain,omitempty" env:"HS_EXPLAIN" env-default:""`      // format of those requests, http or curl, implies dry_run
+
+	Protected bool `yaml:"protected,omitempty" env:"HS_PROTECTED" env-default:"false"` // refuse destructive commands, e.g. in the profile of the production API
+
+	// Logging, see logging.Setup
+	LogLevel  string `yaml:"log_level,omitempty"  env:"HS_LOG_LEVEL" env-default:""`  // debug, info, warn or error, defaults to info
+	LogFormat string `yaml:"log_format,omitempty" env:"HS_LOG_FORMAT" env-default:""` // text or json, defaults to text
+	LogFile   string `yaml:"log_file,omitempty"   env:"HS_LOG_FILE" env-default:""`   // append the logs to this file instead of stderr
+
+	SessionWarning string `yaml:"session_warning,omitempty" env:"HS_SESSION_WARNING" env-default:""` // warn when the session expires within this duration, "0" disables
+
+	// Alternatives to a plaintext password, see LoadPassword
+	PasswordFile    string `yaml:"password_file,omitempty"    env:"HS_PASSWORD_FILE" env-default:""`
+	PasswordCommand string `yaml:"password_command,omitempty" env:"HS_PASSWORD_COMMAND" env-default:""`
+
+	// Encryption at rest of the cookie jar and saved credentials, see LoadPassphrase
+	Encrypt           bool   `yaml:"encrypt,omitempty"            env:"HS_ENCRYPT" env-default:"false"`
+	PassphraseFile    string `yaml:"passphrase_file,omitempty"    env:"HS_PASSPHRASE_FILE" env-default:""`
+	PassphraseCommand string `yaml:"passphrase_command,omitempty" env:"HS_PASSPHRASE_COMMAND" env-default:""`
+
+	Profile string            `yaml:"-" env:"-"` // selected profile, empty if none
+	File    string            `yaml:"-" env:"-"` // configuration file in use, empty if none
+	Sources map[string]string `yaml:"-" env:"-"` // where each value came from, keyed by yaml name, e.g. "root": "env"
+
+	passphrase string          // cached by LoadPassphrase
+	set        map[string]bool // keys given explicitly, even with zero values, see MarkSet
 }
 
-// Attempts to load config from file and environment if any config parameter is not provided as a CLI argument
-// Reads config from file (which in turn might be overwritten by environment in ReadConfig call)
-// If the file can't be read only loads configuration from environment
-func LoadConfig(cfg *Config, cfgPath string) error {
-	if cfg.Root != "" && cfg.User != "" && cfg.Password != "" && cfg.CookieJarPath != "" {
-		return nil
+// Sources of configuration values
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceProfile = "profile"
+	SourceDefault = "default"
+)
+
+// Loads the configuration, cfg holds the values passed as CLI arguments, those given explicitly marked with MarkSet.
+// The file is cfgPath if not empty, otherwise the first one found by Discover.
+// Values are taken in order of preference from CLI arguments, environment, the selected profile and the top level of the file.
+// The profile is selected by the profile argument, the HS_PROFILE environment variable or the current-profile key of the file
+func LoadConfig(cfg *Config, cfgPath string, profile string) error {
+	flags := *cfg
+	*cfg = Config{Sources: map[string]string{}}
+
+	fileSource := SourceFlag
+	if cfgPath == "" {
+		cfgPath, fileSource = Discover()
 	}
 
+	file := &File{}
 	if cfgPath != "" {
 		logging.LogDebug("Attempting to load configuration from file %s", cfgPath)
-		if err := cleanenv.ReadConfig(cfgPath, cfg); err != nil {
+		f, err := ReadFile(cfgPath)
+		if err != nil {
 			if !errors.Is(err, fs.ErrNotExist) {
 				logging.LogDebug("Failed reading configuration: %s", err)
 				return cli.Exit("Failed loading configuration", EX_CONFIG)
 			}
+		} else {
+			if fileSource == SourceProject {
+				if err := f.checkProject(cfgPath); err != nil {
+					return cli.Exit(fmt.Sprintf("Refusing the project configuration: %s", err), EX_CONFIG)
+				}
+			}
+			file = f
+			cfg.File = cfgPath
+			cfg.Sources["file"] = fileSource
 		}
 	}
 
+	switch {
+	case profile != "":
+		cfg.Sources["profile"] = SourceFlag
+	case os.Getenv("HS_PROFILE") != "":
+		profile = os.Getenv("HS_PROFILE")
+		cfg.Sources["profile"] = SourceEnv
+	case file.CurrentProfile != "":
+		profile = file.CurrentProfile
+		cfg.Sources["profile"] = SourceFile
+	}
+
+	merge(cfg, &file.Config, SourceFile)
+	if profile != "" {
+		p, ok := file.Profiles[profile]
+		if !ok {
+			return cli.Exit(fmt.Sprintf("Profile '%s' not found", profile), EX_CONFIG)
+		}
+		logging.LogDebug("Using profile %s", profile)
+		merge(cfg, p, SourceProfile)
+	}
+
 	logging.LogDebug("Attempting to load configuration from environment")
-	if err := cleanenv.ReadEnv(cfg); err != nil {
+	var env Config
+	if err := cleanenv.ReadEnv(&env); err != nil {
 		logging.LogDebug("Failed reading configuration from environment: %s", err)
 		return cli.Exit("Failed loading configuration", EX_CONFIG)
 	}
+	markEnv(&env)
+	merge(cfg, &env, SourceEnv)
+	merge(cfg, &flags, SourceFlag)
+
+	cfg.Profile = profile
+	if cfg.CookieJarPath == "" {
+		cfg.CookieJarPath = DefaultCookieJarPath(profile)
+		cfg.Sources["cookiejar"] = SourceDefault
+	}
+	return nil
+}
+
+// Loads a profile of the configuration file at cfgPath alone, without the environment and CLI arguments, e.g. for a second API.
+// fileSource tells how the file was found, see Discover. The cookie jar defaults to the one of the profile
+func LoadProfile(cfg *Config, cfgPath string, fileSource string, profile string) error {
+	file, err := ReadFile(cfgPath)
+	if err != nil {
+		logging.LogDebug("Failed reading configuration: %s", err)
+		return cli.Exit("Failed loading configuration", EX_CONFIG)
+	}
+	if fileSource == SourceProject {
+		if err := file.checkProject(cfgPath); err != nil {
+			return cli.Exit(fmt.Sprintf("Refusing the project configuration: %s", err), EX_CONFIG)
+		}
+	}
+	p, ok := file.Profiles[profile]
+	if !ok {
+		return cli.Exit(fmt.Sprintf("Profile '%s' not found", profile), EX_CONFIG)
+	}
 
-	// Check that all values were populated
+	*cfg = Config{Sources: map[string]string{"file": fileSource, "profile": SourceFlag}}
+	merge(cfg, &file.Config, SourceFile)
+	merge(cfg, p, SourceProfile)
+	cfg.Profile, cfg.File = profile, cfgPath
+	if cfg.CookieJarPath == "" {
+		cfg.CookieJarPath = DefaultCookieJarPath(profile)
+		cfg.Sources["cookiejar"] = SourceDefault
+	}
+	return nil
+}
+
+// Checks that the values needed to talk to the API are present.
+// The password is only needed to login and is checked by LoadPassword
+func (cfg *Config) Validate() error {
 	if cfg.Root == "" {
 		return cli.Exit("Missing 'root' config parameter", EX_CONFIG)
 	}
 	if cfg.User == "" {
 		return cli.Exit("Missing 'user' config parameter", EX_CONFIG)
 	}
-	if cfg.Password == "" {
-		return cli.Exit("Missing 'password' config parameter", EX_CONFIG)
+	return nil
+}
+
+// Copies the configuration values set in src into dst, recording source in dst.Sources if not empty.
+// A value is set if it isn't zero or was given explicitly, so e.g. insecure: false in a profile turns off insecure: true of the top level
+func merge(dst *Config, src *Config, source string) {
+	// the ways of giving a password exclude each other, the one from the preferred source wins
+	if src.isSet("password") || src.isSet("password_file") || src.isSet("password_command") {
+		dst.Password, dst.PasswordFile, dst.PasswordCommand = "", "", ""
 	}
-	if cfg.CookieJarPath == "" {
-		return cli.Exit("Missing 'cookiejar' config parameter", EX_CONFIG)
+
+	d := reflect.ValueOf(dst).Elem()
+	s := reflect.ValueOf(src).Elem()
+	for i := 0; i < s.NumField(); i++ {
+		tag := d.Type().Field(i).Tag.Get("yaml")
+		if tag == "-" || !d.Type().Field(i).IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if !s.Field(i).IsZero() || src.set[name] {
+			d.Field(i).Set(s.Field(i))
+			if source != "" && dst.Sources != nil {
+				dst.Sources[name] = source
+			}
+		}
 	}
+}
 
-	return nil
+// Marks the value of a key, see Keys, as given explicitly so it's kept by merge even when zero, e.g. for --insecure=false
+func (cfg *Config) MarkSet(key string) {
+	if cfg.set == nil {
+		cfg.set = map[string]bool{}
+	}
+	cfg.set[key] = true
+}
+
+// Marks the values of the environment variables that aren't empty as given explicitly, e.g. HS_INSECURE=false
+func markEnv(cfg *Config) {
+	t := reflect.TypeOf(*cfg)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		env := t.Field(i).Tag.Get("env")
+		if name != "-" && env != "" && env != "-" && os.Getenv(env) != "" {
+			cfg.MarkSet(name)
+		}
+	}
+}
+
+// Whether the configuration value with the key, see Keys, isn't zero or was given explicitly
+func (cfg *Config) isSet(key string) bool {
+	if cfg.set[key] {
+		return true
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == key {
+			return !v.Field(i).IsZero()
+		}
+	}
+	return false
+}
+
+// Names of the configuration values as used in the file, in declaration order
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("yaml")
+		if tag == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// Value of a configuration key as a string, see Keys
+func (cfg *Config) Get(key string) string {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == key {
+			return fmt.Sprint(v.Field(i).Interface())
+		}
+	}
+	return ""
 }
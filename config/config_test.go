@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigExplicitZeros(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "root: http://api.example\ninsecure: true\nprotected: true\nprofiles:\n  dev:\n    protected: false\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unsetenv(t, "HS_PROFILE")
+	unsetenv(t, "HS_INSECURE")
+
+	var cfg Config
+	if err := LoadConfig(&cfg, path, "dev"); err != nil {
+		t.Fatalf("LoadConfig: %s", err)
+	}
+	if cfg.Protected || cfg.Sources["protected"] != SourceProfile {
+		t.Errorf("protected = %t from %s, want false from the profile", cfg.Protected, cfg.Sources["protected"])
+	}
+	if !cfg.Insecure {
+		t.Errorf("insecure = false, want true from the top level")
+	}
+
+	t.Setenv("HS_INSECURE", "false")
+	cfg = Config{}
+	if err := LoadConfig(&cfg, path, "dev"); err != nil {
+		t.Fatalf("LoadConfig: %s", err)
+	}
+	if cfg.Insecure || cfg.Sources["insecure"] != SourceEnv {
+		t.Errorf("insecure = %t from %s, want false from the environment", cfg.Insecure, cfg.Sources["insecure"])
+	}
+
+	unsetenv(t, "HS_INSECURE")
+	cfg = Config{}
+	cfg.MarkSet("protected") // --protected=false
+	if err := LoadConfig(&cfg, path, ""); err != nil {
+		t.Fatalf("LoadConfig: %s", err)
+	}
+	if cfg.Protected || cfg.Sources["protected"] != SourceFlag {
+		t.Errorf("protected = %t from %s, want false from the flag", cfg.Protected, cfg.Sources["protected"])
+	}
+}
+
+// Unsets an environment variable for the test, as cleanenv fails parsing empty ones
+func unsetenv(t *testing.T, name string) {
+	t.Setenv(name, "") // restored after the test
+	os.Unsetenv(name)
+}
+
+func TestSetKey(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"replaced", "# shared\nroot: x # the API\ncurrent-profile: dev # default\nprofiles:\n  dev: {}\n", "# shared\nroot: x # the API\ncurrent-profile: \"on\" # default\nprofiles:\n  dev: {}\n"},
+		{"appended", "root: x\nprofiles:\n  dev: {}", "root: x\nprofiles:\n  dev: {}\ncurrent-profile: \"on\"\n"},
+		{"empty", "", "current-profile: \"on\"\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tc.in), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := setKey(path, "current-profile", "on"); err != nil {
+				t.Fatalf("setKey: %s", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("file = %q, want %q", got, tc.want)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0o644 {
+				t.Errorf("permissions = %o, want 644 kept", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	sample := map[reflect.Kind]string{reflect.String: "x", reflect.Bool: "false", reflect.Int: "0"}
+	values := map[string]string{}
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if name != "-" && typ.Field(i).IsExported() {
+			values[name] = sample[typ.Field(i).Type.Kind()]
+		}
+	}
+
+	for _, key := range Keys() {
+		for _, data := range []string{
+			fmt.Sprintf("%s: %s\n", key, values[key]),
+			fmt.Sprintf("current-profile: dev\nprofiles:\n  dev:\n    %s: %s\n", key, values[key]),
+		} {
+			path := filepath.Join(dir, ".hscli.yaml")
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile %q: %s", data, err)
+			}
+			err = f.checkProject(path)
+			if allowed := slices.Contains(projectKeys, key); allowed != (err == nil) {
+				t.Errorf("checkProject of %q = %v, want allowed %t", data, err, allowed)
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Contents of a configuration file.
+// Top level values are shared by all profiles and overwritten by the selected profile
+type File struct {
+	Config         `yaml:",inline"`
+	CurrentProfile string             `yaml:"current-profile,omitempty"`
+	Profiles       map[string]*Config `yaml:"profiles,omitempty"`
+}
+
+// Reads and parses a configuration file
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("yaml.Unmarshal %s: %w", path, err)
+	}
+
+	// the keys present, to tell e.g. insecure: false from a missing insecure, see merge
+	var keys struct {
+		Top      map[string]yaml.Node            `yaml:",inline"`
+		Profiles map[string]map[string]yaml.Node `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("yaml.Unmarshal %s: %w", path, err)
+	}
+	for key := range keys.Top {
+		f.MarkSet(key)
+	}
+	for name, p := range f.Profiles {
+		if p == nil {
+			p = &Config{}
+			f.Profiles[name] = p
+		}
+		for key := range keys.Profiles[name] {
+			p.MarkSet(key)
+		}
+	}
+	return &f, nil
+}
+
+// How Discover reports a .hscli.yaml found in a project, which anyone able to write to the project could have put there
+const SourceProject = "project"
+
+// The only keys a project file can set, besides current-profile and profiles, as they can't run commands, send the password
+// or the session elsewhere, or write or read files. Only the files of the user, found under their home directory or passed
+// with --config or HS_CONFIG, can set the others
+var projectKeys = []string{"output", "log_level", "log_format", "timeout", "connect_timeout"}
+
+// Fails if the top level or a profile of a project file sets a key that isn't one of projectKeys
+func (f *File) checkProject(path string) error {
+	configs := map[string]*Config{"": &f.Config}
+	for name, p := range f.Profiles {
+		configs[name] = p
+	}
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		var set []string
+		for _, key := range Keys() {
+			if !slices.Contains(projectKeys, key) && configs[name].isSet(key) {
+				set = append(set, key)
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+		where := path
+		if name != "" {
+			where = fmt.Sprintf("profile '%s' of %s", name, path)
+		}
+		return fmt.Errorf("%s sets %s, only %s or a file passed with --config or HS_CONFIG can. A project file can set %s",
+			where, strings.Join(set, ", "), UserConfigPath(), strings.Join(projectKeys, ", "))
+	}
+	return nil
+}
+
+// Names of the profiles in the file, sorted
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Values of a profile, the top level values overwritten by the ones set in the profile
+func (f *File) Resolve(profile string) (Config, bool) {
+	cfg := f.Config
+	if profile == "" {
+		return cfg, true
+	}
+	p, ok := f.Profiles[profile]
+	if !ok {
+		return cfg, false
+	}
+	merge(&cfg, p, "")
+	return cfg, true
+}
+
+// Sets the current-profile key of the configuration file at path, keeping the rest of the file untouched
+func SetCurrentProfile(path string, profile string) error {
+	f, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Profiles[profile]; !ok {
+		return fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+	return setKey(path, "current-profile", profile)
+}
+
+// Sets a top level scalar key of a YAML file, changing only the line of the key, or appending it, so comments,
+// ordering and formatting are kept, as are the permissions of the file
+func setKey(path string, key string, value string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("yaml.Unmarshal %s: %w", path, err)
+	}
+	encoded, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("yaml.Marshal: %w", err)
+	}
+	encoded = bytes.TrimSuffix(encoded, []byte("\n"))
+
+	if doc.Kind == 0 { // empty file, or only comments
+		return os.WriteFile(path, appendLine(data, fmt.Sprintf("%s: %s", key, encoded)), info.Mode().Perm())
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+		return fmt.Errorf("%s: expected a block mapping at the top level", path)
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		if k.Value != key {
+			continue
+		}
+		if v.Kind != yaml.ScalarNode || v.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 || v.Line != k.Line {
+			return fmt.Errorf("%s:%d: expected %s to be a scalar on the line of its key", path, k.Line, key)
+		}
+		line := lines[v.Line-1]
+		replaced := slices.Concat(line[:v.Column-1], encoded)
+		if v.LineComment != "" {
+			replaced = slices.Concat(replaced, []byte(" "+v.LineComment))
+		}
+		if bytes.HasSuffix(line, []byte("\n")) {
+			replaced = append(replaced, '\n')
+		}
+		lines[v.Line-1] = replaced
+		return os.WriteFile(path, bytes.Join(lines, nil), info.Mode().Perm())
+	}
+	return os.WriteFile(path, appendLine(data, fmt.Sprintf("%s: %s", key, encoded)), info.Mode().Perm())
+}
+
+// Appends a line to data, ending the last line first if needed
+func appendLine(data []byte, line string) []byte {
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	return append(data, line+"\n"...)
+}
+
+// Finds the configuration file to use when none is passed with --config.
+// Looks in order for:
+//   - $HS_CONFIG
+//   - .hscli.yaml in the working directory, not in its parents, so leaving a project never picks up its file
+//   - $XDG_CONFIG_HOME/hscli/config.yaml
+//   - ~/.config/hscli/config.yaml
+//
+// Returns the first one that exists and how it was found, or empty strings if none does
+func Discover() (path string, source string) {
+	if p := os.Getenv("HS_CONFIG"); p != "" {
+		return p, SourceEnv
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		p := filepath.Join(wd, ".hscli.yaml")
+		if exists(p) {
+			return p, SourceProject
+		}
+	}
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		p := filepath.Join(xdg, "hscli", "config.yaml")
+		if exists(p) {
+			return p, "xdg"
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		p := filepath.Join(home, ".config", "hscli", "config.yaml")
+		if exists(p) {
+			return p, "home"
+		}
+	}
+	return "", ""
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Configuration file of the user, $XDG_CONFIG_HOME/hscli/config.yaml or ~/.config/hscli/config.yaml
+func UserConfigPath() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "hscli", "config.yaml")
+	}
+	return filepath.Join("~", ".config", "hscli", "config.yaml")
+}
+
+// Directory where the program keeps its files, $XDG_CONFIG_HOME/hscli on linux
+func Dir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "hscli")
+}
+
+// Cookie jar path used when none is configured, each profile gets its own
+func DefaultCookieJarPath(profile string) string {
+	if profile == "" {
+		profile = "default"
+	}
+	return filepath.Join(Dir(), "cookiejars", profile+".json")
+}
+
+// Where the password is saved encrypted when encrypt is set, each profile gets its own
+func CredentialsPath(profile string) string {
+	if profile == "" {
+		profile = "default"
+	}
+	return filepath.Join(Dir(), "credentials", profile+".enc")
+}
+
+// Where the journal of changes kept for undo is written, shared by all profiles
+func JournalDir() string {
+	return filepath.Join(Dir(), "journal")
+}
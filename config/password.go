@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hscli/store"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var ErrNoPassword = errors.New("no password configured")
+
+var ErrNoPassphrase = errors.New("no passphrase configured")
+
+// Resolves the password when needed, i.e. right before logging in.
+// Uses, in order, the password value, the password file, the password command,
+// the encrypted saved credentials if encrypt is set, and an interactive prompt if a terminal is attached
+func (cfg *Config) LoadPassword() error {
+	if cfg.Password == "" && cfg.PasswordFile == "" && cfg.PasswordCommand == "" && cfg.Encrypt {
+		passphrase, err := cfg.LoadPassphrase()
+		if err != nil {
+			return err
+		}
+		password, err := store.LoadCredentials(CredentialsPath(cfg.Profile), passphrase)
+		if err != nil && !errors.Is(err, store.ErrNoCredentials) {
+			return fmt.Errorf("store.LoadCredentials: %w", err)
+		}
+		cfg.Password = password
+	}
+
+	switch {
+	case cfg.Password != "":
+		return nil
+	case cfg.PasswordFile != "":
+		data, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile %s: %w", cfg.PasswordFile, err)
+		}
+		cfg.Password = firstLine(string(data))
+	case cfg.PasswordCommand != "":
+		out, err := shellCommand(cfg.PasswordCommand).Output()
+		if err != nil {
+			return fmt.Errorf("password command %q: %w", cfg.PasswordCommand, err)
+		}
+		cfg.Password = firstLine(string(out))
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		fmt.Fprintf(os.Stderr, "Password for %s at %s: ", cfg.User, cfg.Root)
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("term.ReadPassword: %w", err)
+		}
+		cfg.Password = string(data)
+	}
+
+	if cfg.Password == "" {
+		return ErrNoPassword
+	}
+	return nil
+}
+
+// Resolves the passphrase protecting the encrypted store, asked at most once.
+// Uses, in order, the HS_PASSPHRASE environment variable, the passphrase file, the passphrase command and an interactive prompt
+func (cfg *Config) LoadPassphrase() (string, error) {
+	if cfg.passphrase != "" {
+		return cfg.passphrase, nil
+	}
+
+	switch {
+	case os.Getenv("HS_PASSPHRASE") != "":
+		cfg.passphrase = os.Getenv("HS_PASSPHRASE")
+	case cfg.PassphraseFile != "":
+		data, err := os.ReadFile(cfg.PassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("os.ReadFile %s: %w", cfg.PassphraseFile, err)
+		}
+		cfg.passphrase = firstLine(string(data))
+	case cfg.PassphraseCommand != "":
+		out, err := shellCommand(cfg.PassphraseCommand).Output()
+		if err != nil {
+			return "", fmt.Errorf("passphrase command %q: %w", cfg.PassphraseCommand, err)
+		}
+		cfg.passphrase = firstLine(string(out))
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		fmt.Fprintf(os.Stderr, "Passphrase for the hscli store: ")
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("term.ReadPassword: %w", err)
+		}
+		cfg.passphrase = string(data)
+	}
+
+	if cfg.passphrase == "" {
+		return "", ErrNoPassphrase
+	}
+	return cfg.passphrase, nil
+}
+
+// Reads the password from r, as done by --password-stdin
+func ReadPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimRight(line, "\r")
+}
+
+// Command run through the system shell, stdin and stderr are the terminal's so tools like pass can prompt
+func shellCommand(command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	return cmd
+}
@@ -0,0 +1,610 @@
+// In-memory implementation of the HackerSchool API, for tests and demos
+package fakeapi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hscli/client"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	SessionCookie     = "session"
+	DefaultSessionTTL = time.Hour
+)
+
+type logo struct {
+	contentType string
+	data        []byte
+}
+
+// Member or project as sent by the clients, kept as is so empty values and fields the client types don't declare survive
+type record map[string]any
+
+// String field of the record, "" if it's missing or isn't a string
+func (r record) str(key string) string {
+	s, _ := r[key].(string)
+	return s
+}
+
+// Record of a client type, e.g. a client.Member
+func toRecord(v any) record {
+	var r record
+	data, _ := json.Marshal(v)
+	json.Unmarshal(data, &r)
+	return r
+}
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+// Fake API server, safe for concurrent use. Members log in with the password they were created with
+type Server struct {
+	SessionTTL time.Duration    // lifetime of the session cookies, defaults to DefaultSessionTTL
+	Now        func() time.Time // clock used for session expiry, defaults to time.Now
+	ETags      bool             // send ETags with members and projects, and honour If-Match when updating them
+
+	mu          sync.Mutex
+	members     map[string]record
+	passwords   map[string]string
+	projects    map[string]record
+	tags        map[string][]string
+	memberships map[string]map[string]client.Membership // by username and project
+	logos       map[string]logo                         // by "members/<username>" or "projects/<name>"
+	sessions    map[string]session                      // by cookie value
+	mux         *http.ServeMux
+}
+
+func New() *Server {
+	s := &Server{
+		members:     map[string]record{},
+		passwords:   map[string]string{},
+		projects:    map[string]record{},
+		tags:        map[string][]string{},
+		memberships: map[string]map[string]client.Membership{},
+		logos:       map[string]logo{},
+		sessions:    map[string]session{},
+		mux:         http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /login", s.login)
+	s.mux.HandleFunc("GET /logout", s.logout)
+
+	s.handle("GET /members", s.listMembers)
+	s.handle("POST /members", s.createMember)
+	s.handle("GET /members/{username}", s.getMember)
+	s.handle("PUT /members/{username}", s.updateMember)
+	s.handle("DELETE /members/{username}", s.deleteMember)
+	s.handle("GET /members/{username}/projects", s.memberProjects)
+	s.handle("GET /members/{username}/{project}", s.getMembership)
+	s.handle("POST /members/{username}/{project}", s.addMembership)
+	s.handle("DELETE /members/{username}/{project}", s.removeMembership)
+	s.handle("GET /members/{username}/tags", s.memberTags)
+	s.handle("PUT /members/{username}/tags", s.addTag)
+	s.handle("DELETE /members/{username}/tags", s.deleteTag)
+	s.handle("GET /members/{username}/logo", s.getLogo("members", "username"))
+	s.handle("POST /members/{username}/logo", s.uploadLogo("members", "username"))
+
+	s.handle("GET /projects", s.listProjects)
+	s.handle("POST /projects", s.createProject)
+	s.handle("GET /projects/{name}", s.getProject)
+	s.handle("PUT /projects/{name}", s.updateProject)
+	s.handle("DELETE /projects/{name}", s.deleteProject)
+	s.handle("GET /projects/{name}/members", s.projectMembers)
+	s.handle("GET /projects/{name}/logo", s.getLogo("projects", "name"))
+	s.handle("POST /projects/{name}/logo", s.uploadLogo("projects", "name"))
+
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mux.ServeHTTP(w, r)
+}
+
+// Registers a handler that requires a valid session
+func (s *Server) handle(pattern string, h http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(SessionCookie)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "not logged in")
+			return
+		}
+		sess, ok := s.sessions[cookie.Value]
+		if !ok || !s.now().Before(sess.expires) {
+			delete(s.sessions, cookie.Value)
+			writeError(w, http.StatusUnauthorized, "session expired")
+			return
+		}
+		h(w, r)
+	})
+}
+
+// Adds a member that can log in with password, replacing any member with the same username
+func (s *Server) AddMember(m client.Member, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putMember(toRecord(m), password)
+}
+
+func (s *Server) AddProject(p client.Project) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.projects[p.Name] = toRecord(p)
+}
+
+// Adds a tag to an existing member
+func (s *Server) AddTag(username, tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.tags[username], tag) {
+		s.tags[username] = append(s.tags[username], tag)
+	}
+}
+
+// Adds an existing member to an existing project
+func (s *Server) AddMembership(username, project string, ms client.Membership) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.memberships[username] == nil {
+		s.memberships[username] = map[string]client.Membership{}
+	}
+	s.memberships[username][project] = ms
+}
+
+// Sets the logo of a member or project, kind is "members" or "projects"
+func (s *Server) SetLogo(kind, name, contentType string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logos[kind+"/"+name] = logo{contentType: contentType, data: data}
+}
+
+// Ends all sessions, as if they had expired
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.sessions)
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Server) putMember(m record, password string) {
+	username := m.str("username")
+	delete(m, "password")
+	delete(m, "tags")
+	s.members[username] = m
+	s.passwords[username] = password
+	if _, ok := s.memberships[username]; !ok {
+		s.memberships[username] = map[string]client.Membership{}
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var creds struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if !readJSON(w, r, &creds) {
+		return
+	}
+	password, ok := s.passwords[creds.Username]
+	if !ok || password != creds.Password {
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	ttl := s.SessionTTL
+	if ttl == 0 {
+		ttl = DefaultSessionTTL
+	}
+	token := make([]byte, 16)
+	rand.Read(token)
+	value := hex.EncodeToString(token)
+	expires := s.now().Add(ttl)
+	s.sessions[value] = session{username: creds.Username, expires: expires}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "logged in"})
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		delete(s.sessions, cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+func (s *Server) listMembers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sorted(s.members))
+}
+
+func (s *Server) createMember(w http.ResponseWriter, r *http.Request) {
+	var m record
+	if !readJSON(w, r, &m) {
+		return
+	}
+	username := m.str("username")
+	if username == "" {
+		writeError(w, http.StatusBadRequest, "username is required")
+		return
+	}
+	if _, ok := s.members[username]; ok {
+		writeError(w, http.StatusConflict, "member already exists")
+		return
+	}
+	s.putMember(m, m.str("password"))
+	writeJSON(w, http.StatusCreated, s.members[username])
+}
+
+func (s *Server) getMember(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.member(w, r)
+	if ok {
+		s.setETag(w, m)
+		writeJSON(w, http.StatusOK, m)
+	}
+}
+
+// Updates the fields present in the body, empty values included, the username can't be changed
+func (s *Server) updateMember(w http.ResponseWriter, r *http.Request) {
+	var body record
+	m, ok := s.member(w, r)
+	if !ok || !s.matches(w, r, m) || !readJSON(w, r, &body) {
+		return
+	}
+	username := r.PathValue("username")
+	password := s.passwords[username]
+	if body.str("password") != "" {
+		password = body.str("password")
+	}
+	m = maps.Clone(m)
+	maps.Copy(m, body)
+	m["username"] = username
+	s.putMember(m, password)
+	s.setETag(w, s.members[username])
+	writeJSON(w, http.StatusOK, s.members[username])
+}
+
+func (s *Server) deleteMember(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.member(w, r)
+	if !ok {
+		return
+	}
+	username := m.str("username")
+	delete(s.members, username)
+	delete(s.passwords, username)
+	delete(s.tags, username)
+	delete(s.memberships, username)
+	delete(s.logos, "members/"+username)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "member deleted"})
+}
+
+func (s *Server) memberProjects(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.member(w, r)
+	if !ok {
+		return
+	}
+	projects := []record{}
+	for _, name := range slices.Sorted(maps.Keys(s.memberships[m.str("username")])) {
+		projects = append(projects, s.projects[name])
+	}
+	writeJSON(w, http.StatusOK, projects)
+}
+
+func (s *Server) getMembership(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.member(w, r)
+	if !ok {
+		return
+	}
+	ms, ok := s.memberships[m.str("username")][r.PathValue("project")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "member not in project")
+		return
+	}
+	writeJSON(w, http.StatusOK, ms)
+}
+
+func (s *Server) addMembership(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.member(w, r)
+	if !ok {
+		return
+	}
+	p, ok := s.projects[r.PathValue("project")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	var ms client.Membership
+	if !readJSON(w, r, &ms) {
+		return
+	}
+	username, name := m.str("username"), p.str("name")
+	if _, ok := s.memberships[username][name]; ok {
+		writeError(w, http.StatusConflict, "member already in project")
+		return
+	}
+	s.memberships[username][name] = ms
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "member added to project"})
+}
+
+func (s *Server) removeMembership(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.member(w, r)
+	if !ok {
+		return
+	}
+	username, project := m.str("username"), r.PathValue("project")
+	if _, ok := s.memberships[username][project]; !ok {
+		writeError(w, http.StatusNotFound, "member not in project")
+		return
+	}
+	delete(s.memberships[username], project)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "member removed from project"})
+}
+
+func (s *Server) memberTags(w http.ResponseWriter, r *http.Request) {
+	if m, ok := s.member(w, r); ok {
+		writeJSON(w, http.StatusOK, s.memberTagList(m.str("username")))
+	}
+}
+
+func (s *Server) addTag(w http.ResponseWriter, r *http.Request) {
+	var tag client.Tag
+	m, ok := s.member(w, r)
+	if !ok || !readJSON(w, r, &tag) {
+		return
+	}
+	if tag.Tag == "" {
+		writeError(w, http.StatusBadRequest, "tag is required")
+		return
+	}
+	if !slices.Contains(s.tags[m.str("username")], tag.Tag) {
+		s.tags[m.str("username")] = append(s.tags[m.str("username")], tag.Tag)
+	}
+	writeJSON(w, http.StatusOK, s.memberTagList(m.str("username")))
+}
+
+func (s *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	var tag client.Tag
+	m, ok := s.member(w, r)
+	if !ok || !readJSON(w, r, &tag) {
+		return
+	}
+	i := slices.Index(s.tags[m.str("username")], tag.Tag)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "tag not found")
+		return
+	}
+	s.tags[m.str("username")] = slices.Delete(s.tags[m.str("username")], i, i+1)
+	writeJSON(w, http.StatusOK, s.memberTagList(m.str("username")))
+}
+
+func (s *Server) memberTagList(username string) []string {
+	if tags := s.tags[username]; tags != nil {
+		return tags
+	}
+	return []string{}
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sorted(s.projects))
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var p record
+	if !readJSON(w, r, &p) {
+		return
+	}
+	name := p.str("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if _, ok := s.projects[name]; ok {
+		writeError(w, http.StatusConflict, "project already exists")
+		return
+	}
+	s.projects[name] = p
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.project(w, r); ok {
+		s.setETag(w, p)
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// Updates the fields present in the body, empty values included, the name can't be changed
+func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
+	var body record
+	p, ok := s.project(w, r)
+	if !ok || !s.matches(w, r, p) || !readJSON(w, r, &body) {
+		return
+	}
+	p = maps.Clone(p)
+	maps.Copy(p, body)
+	p["name"] = r.PathValue("name")
+	s.projects[r.PathValue("name")] = p
+	s.setETag(w, p)
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.project(w, r)
+	if !ok {
+		return
+	}
+	name := p.str("name")
+	delete(s.projects, name)
+	for _, ms := range s.memberships {
+		delete(ms, name)
+	}
+	delete(s.logos, "projects/"+name)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "project deleted"})
+}
+
+func (s *Server) projectMembers(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.project(w, r)
+	if !ok {
+		return
+	}
+	members := []record{}
+	for _, m := range sorted(s.members) {
+		if _, ok := s.memberships[m.str("username")][p.str("name")]; ok {
+			members = append(members, m)
+		}
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+// Handler serving the logo of a member or project, key is the path value naming it
+func (s *Server) getLogo(kind, key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.exists(w, kind, r.PathValue(key)) {
+			return
+		}
+		l, ok := s.logos[kind+"/"+r.PathValue(key)]
+		if !ok {
+			writeError(w, http.StatusNotFound, "no logo")
+			return
+		}
+		w.Header().Set("Content-Type", l.contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(l.data)
+	}
+}
+
+// Handler storing the logo uploaded in the "file" field of a multipart form
+func (s *Server) uploadLogo(kind, key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.exists(w, kind, r.PathValue(key)) {
+			return
+		}
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "missing file")
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		contentType := hdr.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = http.DetectContentType(data)
+		}
+		s.logos[kind+"/"+r.PathValue(key)] = logo{contentType: contentType, data: data}
+		writeJSON(w, http.StatusCreated, map[string]string{"message": "logo uploaded"})
+	}
+}
+
+func (s *Server) member(w http.ResponseWriter, r *http.Request) (record, bool) {
+	m, ok := s.members[r.PathValue("username")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "member not found")
+	}
+	return m, ok
+}
+
+func (s *Server) project(w http.ResponseWriter, r *http.Request) (record, bool) {
+	p, ok := s.projects[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "project not found")
+	}
+	return p, ok
+}
+
+func (s *Server) exists(w http.ResponseWriter, kind, name string) bool {
+	ok := false
+	if kind == "members" {
+		_, ok = s.members[name]
+	} else {
+		_, ok = s.projects[name]
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, kind[:len(kind)-1]+" not found")
+	}
+	return ok
+}
+
+// Values of the map sorted by key, so listings are stable
+func sorted[T any](m map[string]T) []T {
+	values := make([]T, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		values = append(values, m[k])
+	}
+	return values
+}
+
+// Strong ETag of a record, a hash of its JSON
+func etag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func (s *Server) setETag(w http.ResponseWriter, v any) {
+	if s.ETags {
+		w.Header().Set("ETag", etag(v))
+	}
+}
+
+// Whether the If-Match header of an update, if any, matches the record, writes a 412 otherwise
+func (s *Server) matches(w http.ResponseWriter, r *http.Request, v any) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if !s.ETags || ifMatch == "" || ifMatch == "*" || ifMatch == etag(v) {
+		return true
+	}
+	writeError(w, http.StatusPreconditionFailed, "modified since it was read")
+	return false
+}
+
+// Decodes the request body into v, an empty body leaves v untouched. Writes a 400 and returns false if invalid
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	data, err := io.ReadAll(r.Body)
+	if err == nil && len(data) > 0 {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,224 @@
+package fakeapi
+
+import (
+	"bytes"
+	"errors"
+	"hscli/client"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Starts a server with an admin member and returns a client logged in as them
+func setup(t *testing.T) (*Server, *client.Client) {
+	t.Helper()
+	s := New()
+	s.AddMember(client.Member{Username: "admin", Name: "Admin"}, "secret")
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	c := client.NewClient()
+	c.Cfg.Root = srv.URL
+	c.Cfg.User = "admin"
+	c.Cfg.Password = "secret"
+	c.Cfg.CookieJarPath = filepath.Join(t.TempDir(), "cookiejar.json")
+	c.SetupJar()
+	if err := c.Login(); err != nil {
+		t.Fatalf("Login: %s", err)
+	}
+	return s, c
+}
+
+func TestLogin(t *testing.T) {
+	s, c := setup(t)
+
+	if _, err := c.Members.List(); err != nil {
+		t.Fatalf("List after login: %s", err)
+	}
+
+	s.ExpireSessions()
+	if _, err := c.Members.List(); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("List with an expired session: got %v, want ErrUnauthorized", err)
+	}
+
+	c.Cfg.Password = "wrong"
+	if err := c.Login(); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("Login with a wrong password: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s, c := setup(t)
+	now := time.Now()
+	s.Now = func() time.Time { return now }
+	s.SessionTTL = time.Minute
+	if err := c.Login(); err != nil {
+		t.Fatalf("Login: %s", err)
+	}
+
+	now = now.Add(59 * time.Second)
+	if _, err := c.Members.List(); err != nil {
+		t.Fatalf("List before expiry: %s", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := c.Members.List(); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("List after expiry: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	_, c := setup(t)
+	if err := c.Logout(); err != nil {
+		t.Fatalf("Logout: %s", err)
+	}
+	if _, err := c.Members.List(); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("List after logout: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	_, c := setup(t)
+
+	if _, err := c.Members.Create(&client.Member{Username: "john", Name: "John", Email: "john@example.com", Password: "pw"}); err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	if _, err := c.Members.Create(&client.Member{Username: "john"}); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("Create existing: got %v, want ErrConflict", err)
+	}
+
+	if _, err := c.Members.Update("john", &client.Member{Username: "john", Name: "John Doe"}); err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+	m, err := c.Members.Get("john")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	want := client.Member{Username: "john", Name: "John Doe", Email: "john@example.com"}
+	if m.Username != want.Username || m.Name != want.Name || m.Email != want.Email || m.Password != "" {
+		t.Fatalf("Get: got %+v, want %+v", *m, want)
+	}
+
+	members, err := c.Members.List()
+	if err != nil {
+		t.Fatalf("List: %s", err)
+	}
+	if len(members) != 2 || members[0].Username != "admin" || members[1].Username != "john" {
+		t.Fatalf("List: got %+v, want admin and john", members)
+	}
+
+	if err := c.Members.Delete("john"); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	if _, err := c.Members.Get("john"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Get deleted: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateIfUnmodified(t *testing.T) {
+	for _, etags := range []bool{false, true} {
+		s, c := setup(t)
+		s.ETags = etags
+		s.AddProject(client.Project{Name: "web", State: "active"})
+
+		p, v, err := c.Projects.GetVersion("web")
+		if err != nil {
+			t.Fatalf("GetVersion: %s", err)
+		}
+		if (v.ETag != "") != etags || v.Hash != client.HashVersion(p).Hash {
+			t.Fatalf("GetVersion with etags %t: got %+v", etags, v)
+		}
+		if _, err := c.Projects.Update("web", &client.Project{Name: "web", State: "paused"}); err != nil {
+			t.Fatalf("Update: %s", err)
+		}
+		if _, err := c.Projects.UpdateIfUnmodified("web", &client.Project{Name: "web", State: "done"}, v); !errors.Is(err, client.ErrModified) {
+			t.Fatalf("UpdateIfUnmodified with etags %t after a change: got %v, want ErrModified", etags, err)
+		}
+
+		_, v, err = c.Projects.GetVersion("web")
+		if err != nil {
+			t.Fatalf("GetVersion: %s", err)
+		}
+		updated, err := c.Projects.UpdateIfUnmodified("web", &client.Project{Name: "web", State: "done"}, v)
+		if err != nil || updated.State != "done" {
+			t.Fatalf("UpdateIfUnmodified with etags %t: got %+v, %v", etags, updated, err)
+		}
+	}
+}
+
+func TestTagsAndMemberships(t *testing.T) {
+	_, c := setup(t)
+	if _, err := c.Projects.Create(&client.Project{Name: "web", State: "active"}); err != nil {
+		t.Fatalf("Create project: %s", err)
+	}
+
+	for _, tag := range []string{"dev", "infra"} {
+		if err := c.Members.AddTag("admin", tag); err != nil {
+			t.Fatalf("AddTag %s: %s", tag, err)
+		}
+	}
+	if err := c.Members.DeleteTag("admin", "dev"); err != nil {
+		t.Fatalf("DeleteTag: %s", err)
+	}
+	tags, err := c.Members.Tags("admin")
+	if err != nil || !slices.Equal(tags, []string{"infra"}) {
+		t.Fatalf("Tags: got %v, %v, want [infra]", tags, err)
+	}
+
+	if err := c.Projects.AddMember("web", "admin", &client.Membership{EntryDate: "2024-10-01"}); err != nil {
+		t.Fatalf("AddMember: %s", err)
+	}
+	if ms, err := c.Members.Membership("admin", "web"); err != nil || ms.EntryDate != "2024-10-01" {
+		t.Fatalf("Membership: got %+v, %v, want entry date 2024-10-01", ms, err)
+	}
+	if err := c.Projects.AddMember("web", "admin", nil); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("AddMember again: got %v, want ErrConflict", err)
+	}
+	if err := c.Members.AddProject("admin", "missing", nil); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("AddProject to a missing project: got %v, want ErrNotFound", err)
+	}
+	members, err := c.Projects.Members("web")
+	if err != nil || len(members) != 1 || members[0].Username != "admin" {
+		t.Fatalf("Members: got %+v, %v, want admin", members, err)
+	}
+	if err := c.Members.RemoveProject("admin", "web"); err != nil {
+		t.Fatalf("RemoveProject: %s", err)
+	}
+	if err := c.Members.RemoveProject("admin", "web"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("RemoveProject again: got %v, want ErrNotFound", err)
+	}
+	if err := c.Members.AddProject("admin", "web", nil); err != nil {
+		t.Fatalf("AddProject after removing it: %s", err)
+	}
+
+	if err := c.Projects.Delete("web"); err != nil {
+		t.Fatalf("Delete project: %s", err)
+	}
+	projects, err := c.Members.Projects("admin")
+	if err != nil || len(projects) != 0 {
+		t.Fatalf("Projects after deleting the project: got %+v, %v, want none", projects, err)
+	}
+	if _, err := c.Members.Membership("admin", "web"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Membership after deleting the project: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestLogos(t *testing.T) {
+	_, c := setup(t)
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	if _, err := c.Members.Logo("admin"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Logo before upload: got %v, want ErrNotFound", err)
+	}
+	if err := c.Members.UploadLogo("admin", "admin.png", bytes.NewReader(png)); err != nil {
+		t.Fatalf("UploadLogo: %s", err)
+	}
+	logo, err := c.Members.Logo("admin")
+	if err != nil || !bytes.Equal(logo, png) {
+		t.Fatalf("Logo: got %q, %v, want %q", logo, err, png)
+	}
+	if err := c.Projects.UploadLogo("missing", "logo.png", bytes.NewReader(png)); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("UploadLogo of a missing project: got %v, want ErrNotFound", err)
+	}
+}
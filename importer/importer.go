@@ -0,0 +1,272 @@
+// Reading of members from CSV and JSONL files for bulk imports
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/client"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Columns holding lists rather than member fields
+const (
+	ColumnTags     = "tags"
+	ColumnProjects = "projects"
+)
+
+// Maps the columns of a file to member fields, read from a YAML or JSON file
+type Mapping struct {
+	Columns   map[string]string `yaml:"columns"`   // column to field, "-" ignores the column, unmapped columns must be named after a field
+	Delimiter string            `yaml:"delimiter"` // CSV delimiter, defaults to ","
+	Separator string            `yaml:"separator"` // separator of the tags and projects lists in CSV cells, defaults to ";"
+}
+
+// A member to import, Err is set if the row is invalid
+type Row struct {
+	Line     int
+	Member   client.Member
+	Tags     []string
+	Projects []string
+	Err      error
+}
+
+func ReadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Mapping
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for column, field := range m.Columns {
+		if field != "-" && !isField(field) {
+			return nil, fmt.Errorf("%s: column %s mapped to unknown field %s", path, column, field)
+		}
+	}
+	if len([]rune(m.Delimiter)) > 1 {
+		return nil, fmt.Errorf("%s: delimiter must be a single character", path)
+	}
+	return &m, nil
+}
+
+// Reads the rows of a .csv or .jsonl file, mapping may be nil.
+// Errors in the file as a whole, such as unknown columns, are returned, errors in single rows are set in Row.Err
+func Read(path string, mapping *Mapping) ([]Row, error) {
+	if mapping == nil {
+		mapping = &Mapping{}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch {
+	case strings.HasSuffix(path, ".csv"):
+		return readCSV(f, mapping)
+	case strings.HasSuffix(path, ".jsonl"), strings.HasSuffix(path, ".ndjson"):
+		return readJSONL(f, mapping)
+	}
+	return nil, fmt.Errorf("%s: unknown format, expected a .csv or .jsonl file", path)
+}
+
+func readCSV(r io.Reader, mapping *Mapping) ([]Row, error) {
+	cr := csv.NewReader(r)
+	if mapping.Delimiter != "" {
+		cr.Comma = []rune(mapping.Delimiter)[0]
+	}
+	cr.FieldsPerRecord = -1 // reported per row instead
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	fields := make([]string, len(header))
+	for i, column := range header {
+		if fields[i], err = mapping.field(strings.TrimSpace(column)); err != nil {
+			return nil, err
+		}
+	}
+	separator := mapping.Separator
+	if separator == "" {
+		separator = ";"
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, Row{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			rows = append(rows, Row{Line: line, Err: fmt.Errorf("expected %d columns, got %d", len(header), len(record))})
+			continue
+		}
+
+		values := map[string]any{}
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if fields[i] == "-" || cell == "" {
+				continue
+			}
+			if fields[i] == ColumnTags || fields[i] == ColumnProjects {
+				values[fields[i]] = splitList(cell, separator)
+			} else {
+				values[fields[i]] = cell
+			}
+		}
+		rows = append(rows, newRow(line, values))
+	}
+}
+
+func readJSONL(r io.Reader, mapping *Mapping) ([]Row, error) {
+	var rows []Row
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal(data, &record); err != nil {
+			rows = append(rows, Row{Line: line, Err: fmt.Errorf("invalid JSON: %w", err)})
+			continue
+		}
+
+		values := map[string]any{}
+		for key, value := range record {
+			field, err := mapping.field(key)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if field != "-" && value != nil {
+				values[field] = value
+			}
+		}
+		rows = append(rows, newRow(line, values))
+	}
+	return rows, scanner.Err()
+}
+
+// Field of a column, "-" if ignored
+func (m *Mapping) field(column string) (string, error) {
+	if field, ok := m.Columns[column]; ok {
+		return field, nil
+	}
+	if isField(column) {
+		return column, nil
+	}
+	return "", fmt.Errorf("unknown column %s, map it to a field or to - to ignore it", column)
+}
+
+func newRow(line int, values map[string]any) Row {
+	row := Row{Line: line}
+	row.Member.Username, _ = values["username"].(string) // for error reports, if the row is invalid
+	var err error
+	if row.Tags, err = list(values, ColumnTags); err != nil {
+		row.Err = err
+		return row
+	}
+	if row.Projects, err = list(values, ColumnProjects); err != nil {
+		row.Err = err
+		return row
+	}
+
+	// CSV cells are strings, numeric fields must be converted before decoding
+	for field, value := range values {
+		if s, ok := value.(string); ok && fieldKind(field) == reflect.Int {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				row.Err = fmt.Errorf("%s: invalid number %q", field, s)
+				return row
+			}
+			values[field] = n
+		}
+	}
+	data, err := json.Marshal(values)
+	if err == nil {
+		err = json.Unmarshal(data, &row.Member)
+	}
+	if err != nil {
+		row.Err = err
+		return row
+	}
+	if row.Member.Username == "" {
+		row.Err = errors.New("missing username")
+	}
+	return row
+}
+
+// Removes a list column from values, accepting either a list or a comma separated string
+func list(values map[string]any, column string) ([]string, error) {
+	value, ok := values[column]
+	delete(values, column)
+	if !ok {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return splitList(v, ","), nil
+	case []string:
+		return v, nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a list of strings", column)
+			}
+			items = append(items, s)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%s: expected a list of strings", column)
+}
+
+func splitList(s string, separator string) []string {
+	var items []string
+	for _, item := range strings.Split(s, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Kind of the member field with the JSON name, reflect.Invalid if there's none
+func fieldKind(name string) reflect.Kind {
+	t := reflect.TypeOf(client.Member{})
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return t.Field(i).Type.Kind()
+		}
+	}
+	return reflect.Invalid
+}
+
+func isField(name string) bool {
+	return slices.Contains([]string{ColumnTags, ColumnProjects}, name) || fieldKind(name) != reflect.Invalid
+}
@@ -0,0 +1,147 @@
+// Journal of the state of members and projects before they were changed, so the changes can be undone
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/backup"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entries kept, the oldest ones are removed when more are appended
+const Keep = 100
+
+// Clock of the entries, replaced by tests
+var Now = time.Now
+
+// A change to a member or project, with the state before it
+type Entry struct {
+	ID      int              `json:"id"`
+	Time    time.Time        `json:"time"`
+	User    string           `json:"user"`              // API user that made the change
+	Profile string           `json:"profile,omitempty"` // profile selected, empty if none
+	Root    string           `json:"root"`              // API changed
+	Command string           `json:"command"`           // e.g. "mupdate john member.json"
+	Kind    string           `json:"kind"`              // member or project
+	Name    string           `json:"name"`              // username or project name
+	Undone  bool             `json:"undone"`
+	Undoes  int              `json:"undoes,omitempty"` // id of the entry undone, for the entries recorded by undo
+	Prior   *backup.Snapshot `json:"prior,omitempty"`  // the record with its tags, memberships and logo, nil if it didn't exist, e.g. before an undo of its deletion
+}
+
+// Entries stored as <id>.json files in a directory
+type Journal struct {
+	Dir string
+}
+
+// Stores a new entry, setting its id and time
+func (j Journal) Append(e *Entry) error {
+	ids, err := j.ids()
+	if err != nil {
+		return err
+	}
+	e.ID = 1
+	if len(ids) > 0 {
+		e.ID = ids[len(ids)-1] + 1
+	}
+	e.Time = Now().UTC()
+	if err := j.Save(e); err != nil {
+		return err
+	}
+
+	for len(ids) >= Keep {
+		if err := os.Remove(j.path(ids[0])); err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// Overwrites a stored entry, e.g. to mark it undone
+func (j Journal) Save(e *Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	if err := os.MkdirAll(j.Dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(j.path(e.ID), data, 0o600) // holds personal data of members
+}
+
+// Reads an entry, fs.ErrNotExist if there's none with the id
+func (j Journal) Get(id int) (*Entry, error) {
+	data, err := os.ReadFile(j.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("entry %d: %w", id, err)
+	}
+	return &e, nil
+}
+
+// Reads all entries, oldest first
+func (j Journal) List() ([]*Entry, error) {
+	ids, err := j.ids()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(ids))
+	for _, id := range ids {
+		e, err := j.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Newest entry not undone yet, skipping those recorded by undo so repeated undos go further back.
+// fs.ErrNotExist if there's none
+func (j Journal) Last() (*Entry, error) {
+	entries, err := j.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range slices.Backward(entries) {
+		if !e.Undone && e.Undoes == 0 {
+			return e, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+func (j Journal) path(id int) string {
+	return filepath.Join(j.Dir, strconv.Itoa(id)+".json")
+}
+
+// Ids of the stored entries, in ascending order
+func (j Journal) ids() ([]int, error) {
+	files, err := os.ReadDir(j.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, f := range files {
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err == nil && strings.HasSuffix(f.Name(), ".json") {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
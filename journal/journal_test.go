@@ -0,0 +1,43 @@
+package journal
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	j := Journal{Dir: t.TempDir()}
+	if _, err := j.Last(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Last of an empty journal: %v, want fs.ErrNotExist", err)
+	}
+
+	for i := 0; i < Keep+2; i++ {
+		if err := j.Append(&Entry{Kind: "member", Name: "john"}); err != nil {
+			t.Fatalf("Append: %s", err)
+		}
+	}
+	entries, err := j.List()
+	if err != nil {
+		t.Fatalf("List: %s", err)
+	}
+	if len(entries) != Keep || entries[0].ID != 3 || entries[Keep-1].ID != Keep+2 {
+		t.Fatalf("List = %d entries from %d to %d, want %d from 3 to %d", len(entries), entries[0].ID, entries[len(entries)-1].ID, Keep, Keep+2)
+	}
+
+	last := entries[Keep-1]
+	last.Undone = true
+	if err := j.Save(last); err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+	if err := j.Append(&Entry{Kind: "member", Name: "john", Undoes: last.ID}); err != nil {
+		t.Fatalf("Append: %s", err)
+	}
+	e, err := j.Last()
+	if err != nil {
+		t.Fatalf("Last: %s", err)
+	}
+	if e.ID != Keep+1 {
+		t.Errorf("Last = entry %d, want %d skipping the undone entry and the undo", e.ID, Keep+1)
+	}
+}
@@ -1,11 +1,98 @@
-// Wrapper functions for slog functions
+// Wrapper functions for slog functions and setup of the default logger
 package logging
 
 import (
 	"fmt"
+	"io"
+	"log"
 	"log/slog"
+	"os"
+	"strings"
 )
 
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+var Formats = []string{Text, JSON}
+
+// Where and what to log, see Setup
+type Options struct {
+	Level  string    // debug, info, warn or error, defaults to info
+	Format string    // text or json, defaults to text
+	File   string    // appended to instead of Writer when not empty
+	Writer io.Writer // console the logs are written to, usually stderr
+	Attrs  []any     // added to every record, e.g. "command", "mget"
+}
+
+var (
+	console = slog.Default() // logs through the log package, as "INFO message key=value"
+	file    *os.File         // opened by Setup, closed by Close
+	flags   = -1             // flags of the log package before a JSON logger took it over, -1 if it didn't
+)
+
+// Replaces the default slog logger, closing the file opened by a previous call
+func Setup(opts Options) error {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	if err := Close(); err != nil {
+		return err
+	}
+	w := opts.Writer
+	if opts.File != "" {
+		f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("log_file: %w", err)
+		}
+		file, w = f, f
+	}
+
+	switch opts.Format {
+	case "", Text:
+		if flags >= 0 { // taken over by a previous JSON logger
+			log.SetFlags(flags)
+			flags = -1
+		}
+		log.SetOutput(w)
+		slog.SetLogLoggerLevel(level)
+		slog.SetDefault(console.With(opts.Attrs...))
+	case JSON:
+		if flags < 0 {
+			flags = log.Flags()
+		}
+		handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+		slog.SetDefault(slog.New(handler).With(opts.Attrs...))
+	default:
+		return fmt.Errorf("log_format: unknown format %q, expected one of %s", opts.Format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+// Closes the log file opened by Setup, if any
+func Close() error {
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// Parses a log level name, an empty name defaults to info
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("log_level: unknown level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
 func LogDebug(format string, args ...any) {
 	slog.Debug(fmt.Sprintf(format, args...))
 }
@@ -14,6 +101,28 @@ func LogInfo(format string, args ...any) {
 	slog.Info(fmt.Sprintf(format, args...))
 }
 
+func LogWarn(format string, args ...any) {
+	slog.Warn(fmt.Sprintf(format, args...))
+}
+
 func LogError(format string, args ...any) {
 	slog.Error(fmt.Sprintf(format, args...))
 }
+
+// Structured variants, logging a fixed message with key value pair attributes, e.g. Debug("Incoming response", "status", 200)
+
+func Debug(msg string, args ...any) {
+	slog.Debug(msg, args...)
+}
+
+func Info(msg string, args ...any) {
+	slog.Info(msg, args...)
+}
+
+func Warn(msg string, args ...any) {
+	slog.Warn(msg, args...)
+}
+
+func Error(msg string, args ...any) {
+	slog.Error(msg, args...)
+}
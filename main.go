@@ -1,37 +1,216 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"hscli/client"
 	"hscli/commands"
 	"hscli/config"
+	"hscli/logging"
+	"hscli/output"
 	"io"
-	"log"
-	"log/slog"
-	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
 
 const EX_USAGE = 64 // https://stackoverflow.com/questions/1101957/are-there-any-standard-exit-status-codes-in-linux
 
+// Commands that don't talk to the API and can run without a complete configuration
+var offlineCommands = map[string]bool{"config": true, "dev": true, "help": true, "h": true, "journal": true}
+
+// Commands that manage the session themselves and don't warn about its expiry
+var sessionCommands = map[string]bool{"login": true, "logout": true, "session": true}
+
+// Commands that build their own clients from profiles and don't use the top level configuration
+var profileCommands = map[string]bool{"migrate": true}
+
 func main() {
+	os.Exit(run(os.Args, os.Stdout, os.Stderr))
+}
+
+// Runs the program with the command line args, writing results to stdout and messages to stderr.
+// Returns the exit code instead of exiting, so it can be run by tests
+func run(args []string, stdout, stderr io.Writer) int {
+	commands.Stdout, commands.Stderr = stdout, stderr
+	logging.Setup(logging.Options{Writer: stderr})
+	defer logging.Close()
+
 	c := client.NewClient()
-	app := &cli.App{
+	c.Stdout = stdout
+	app := newApp(c)
+	app.Writer, app.ErrWriter = stdout, stderr
+	app.ExitErrHandler = func(*cli.Context, error) {} // handled below instead of exiting
+	if err := app.Run(args); err != nil {
+		if msg := err.Error(); msg != "" {
+			fmt.Fprintln(stderr, msg)
+		}
+		var exitErr cli.ExitCoder
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		return 1
+	}
+	return 0
+}
+
+// Logging options of the configuration, records are tagged with the command being run
+func logOptions(cCtx *cli.Context, cfg *config.Config) logging.Options {
+	opts := logging.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, File: cfg.LogFile, Writer: cCtx.App.ErrWriter}
+	if cCtx.Bool("debug") {
+		opts.Level = "debug"
+	}
+	if cmd := cCtx.Args().First(); cmd != "" {
+		opts.Attrs = []any{"command", cmd}
+	}
+	return opts
+}
+
+// Value of a flag that can be repeated, unlike cli.StringSliceFlag values aren't split on commas
+type repeatedValue []string
+
+func (v *repeatedValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+func (v *repeatedValue) String() string {
+	return strings.Join(*v, ", ")
+}
+
+// Flags of mupdate and pupdate
+func updateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.GenericFlag{
+			Name:  "set",
+			Usage: "set a field, as `key=value`, the value being JSON or else a string, e.g. --set member_number=42 --set name=\"John Doe\"",
+			Value: &repeatedValue{},
+		},
+		&cli.GenericFlag{
+			Name:  "unset",
+			Usage: "clear a field",
+			Value: &repeatedValue{},
+		},
+		&cli.StringFlag{
+			Name:  "merge-patch",
+			Usage: "JSON Merge Patch (RFC 7386) `file` to apply to the current record",
+		},
+		&cli.StringFlag{
+			Name:  "json-patch",
+			Usage: "JSON Patch (RFC 6902) `file` of operations to apply to the current record",
+		},
+		&cli.StringFlag{
+			Name:  "base",
+			Usage: "`file` with the record as read before changing it, e.g. by mget, the update is refused if the record changed since",
+		},
+		&cli.BoolFlag{
+			Name:  "merge",
+			Usage: "merge the update with changes made to other fields since the record was read, instead of asking or refusing",
+		},
+	}
+}
+
+// Patch options of mupdate and pupdate, applied in the order merge patch, JSON patch, --set and --unset
+func patchOptions(cCtx *cli.Context) commands.PatchOptions {
+	return commands.PatchOptions{
+		MergePatch: cCtx.String("merge-patch"),
+		JSONPatch:  cCtx.String("json-patch"),
+		Set:        *cCtx.Generic("set").(*repeatedValue),
+		Unset:      *cCtx.Generic("unset").(*repeatedValue),
+		Merge:      cCtx.Bool("merge"),
+	}
+}
+
+// Configuration key set by a global flag, e.g. max_attempts for --max-attempts
+func configKey(flag string) string {
+	if flag == "cookie-jar" {
+		return "cookiejar"
+	}
+	return strings.ReplaceAll(flag, "-", "_")
+}
+
+// Command line of the command being run, without the global options, e.g. "mupdate --set year=3 john"
+func commandLine(cCtx *cli.Context) string {
+	line := []string{cCtx.Command.Name}
+	for _, f := range cCtx.Command.Flags {
+		name := f.Names()[0]
+		if !cCtx.IsSet(name) {
+			continue
+		}
+		switch f.(type) {
+		case *cli.BoolFlag:
+			line = append(line, "--"+name)
+		case *cli.GenericFlag:
+			for _, v := range *cCtx.Generic(name).(*repeatedValue) {
+				line = append(line, "--"+name, v)
+			}
+		case *cli.StringSliceFlag:
+			for _, v := range cCtx.StringSlice(name) {
+				line = append(line, "--"+name, v)
+			}
+		default:
+			line = append(line, "--"+name, cCtx.String(name))
+		}
+	}
+	return strings.Join(append(line, cCtx.Args().Slice()...), " ")
+}
+
+// Exit error for a command exit code, nil on success
+func exit(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return cli.Exit("", code)
+}
+
+func newApp(c *client.Client) *cli.App {
+	return &cli.App{
 		Name:                 client.ProgramName,
 		Version:              client.ProgramVersion,
 		Usage:                "CLI client for the HackerSchool API",
 		EnableBashCompletion: true,
 		// Load config from file or environment
 		Before: func(cCtx *cli.Context) error {
-			if cCtx.Bool("debug") {
-				slog.SetLogLoggerLevel(slog.LevelDebug)
+			// log with the flags while the configuration loads, then with the whole configuration
+			if err := logging.Setup(logOptions(cCtx, c.Cfg)); err != nil {
+				return cli.Exit(err.Error(), EX_USAGE)
+			}
+			if cCtx.Bool("password-stdin") {
+				password, err := config.ReadPassword(commands.Stdin)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("Failed reading password from stdin: %s", err), config.EX_CONFIG)
+				}
+				c.Cfg.Password = password
 			}
-			if err := config.LoadConfig(c.Cfg, cCtx.String("config")); err != nil {
+			for _, f := range cCtx.App.Flags {
+				if name := f.Names()[0]; cCtx.IsSet(name) {
+					c.Cfg.MarkSet(configKey(name)) // kept even when zero, e.g. --insecure=false
+				}
+			}
+			if err := config.LoadConfig(c.Cfg, cCtx.String("config"), cCtx.String("profile")); err != nil {
 				return err
 			}
+			if !offlineCommands[cCtx.Args().First()] && !profileCommands[cCtx.Args().First()] {
+				if err := c.Cfg.Validate(); err != nil {
+					return err
+				}
+			}
+			if err := logging.Setup(logOptions(cCtx, c.Cfg)); err != nil {
+				return cli.Exit(fmt.Sprintf("Invalid configuration: %s", err), config.EX_CONFIG)
+			}
+			if _, err := output.ParseFormat(c.Cfg.Output); err != nil {
+				return cli.Exit(err.Error(), EX_USAGE)
+			}
+			if err := c.SetupTransport(); err != nil {
+				return cli.Exit(fmt.Sprintf("Invalid configuration: %s", err), config.EX_CONFIG)
+			}
 			c.SetupJar()
+			if !offlineCommands[cCtx.Args().First()] && !sessionCommands[cCtx.Args().First()] && !profileCommands[cCtx.Args().First()] {
+				commands.WarnSessionExpiry(c)
+			}
 			return nil
 		},
 		Flags: []cli.Flag{
@@ -39,7 +218,12 @@ func main() {
 				Name:    "config",
 				Aliases: []string{"f"},
 				Value:   "",
-				Usage:   "path to config file",
+				Usage:   "path to config file (defaults to $HS_CONFIG, .hscli.yaml, $XDG_CONFIG_HOME/hscli/config.yaml or ~/.config/hscli/config.yaml)",
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Value: "",
+				Usage: "configuration profile to use (overwrites file current-profile and HS_PROFILE environment configs)",
 			},
 			&cli.StringFlag{
 				Name:        "root",
@@ -62,18 +246,125 @@ func main() {
 				Usage:       "user password       (overwrites file and HS_PASSWORD environment configs)",
 				Destination: &c.Cfg.Password,
 			},
+			&cli.BoolFlag{
+				Name:  "password-stdin",
+				Usage: "read the password from the first line of stdin, stdin can't be used for payloads then",
+			},
+			&cli.StringFlag{
+				Name:        "password-file",
+				Value:       "",
+				Usage:       "read the password from a file (overwrites file and HS_PASSWORD_FILE environment configs)",
+				Destination: &c.Cfg.PasswordFile,
+			},
 			&cli.StringFlag{
 				Name:        "cookie-jar",
 				Aliases:     []string{"c"},
 				Value:       "",
-				Usage:       "cookie jar path     (overwrites file and HS_COOKIEJAR environment configs)",
+				Usage:       "cookie jar path     (overwrites file and HS_COOKIEJAR environment configs, defaults to one per profile)",
 				Destination: &c.Cfg.CookieJarPath,
 			},
-
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       "",
+				Usage:       "output format, one of json, json-pretty, yaml, csv, table or template=<go-template> (overwrites file and HS_OUTPUT environment configs)",
+				Destination: &c.Cfg.Output,
+			},
+			&cli.StringFlag{
+				Name:        "timeout",
+				Usage:       "timeout of a whole request, including retries, e.g. 30s (overwrites file and HS_TIMEOUT environment configs) (default: 100s)",
+				Destination: &c.Cfg.Timeout,
+			},
+			&cli.StringFlag{
+				Name:        "connect-timeout",
+				Usage:       "timeout establishing a connection (overwrites file and HS_CONNECT_TIMEOUT environment configs) (default: 30s)",
+				Destination: &c.Cfg.ConnectTimeout,
+			},
+			&cli.StringFlag{
+				Name:        "proxy",
+				Usage:       "http(s):// or socks5:// proxy URL (overwrites file and HS_PROXY environment configs, defaults to HTTP_PROXY/HTTPS_PROXY)",
+				Destination: &c.Cfg.Proxy,
+			},
+			&cli.StringFlag{
+				Name:        "cacert",
+				Usage:       "PEM file with extra certificate authorities to trust (overwrites file and HS_CACERT environment configs)",
+				Destination: &c.Cfg.CACert,
+			},
+			&cli.StringFlag{
+				Name:        "client-cert",
+				Usage:       "PEM client certificate for mutual TLS (overwrites file and HS_CLIENT_CERT environment configs)",
+				Destination: &c.Cfg.ClientCert,
+			},
+			&cli.StringFlag{
+				Name:        "client-key",
+				Usage:       "PEM client key for mutual TLS (overwrites file and HS_CLIENT_KEY environment configs)",
+				Destination: &c.Cfg.ClientKey,
+			},
+			&cli.BoolFlag{
+				Name:        "insecure",
+				Aliases:     []string{"k"},
+				Usage:       "skip TLS certificate verification (overwrites file and HS_INSECURE environment configs)",
+				Destination: &c.Cfg.Insecure,
+			},
+			&cli.IntFlag{
+				Name:        "max-attempts",
+				Usage:       "attempts per request on connection errors, 429, 502, 503 and 504, 1 disables retries (overwrites file and HS_MAX_ATTEMPTS environment configs) (default: 3)",
+				Destination: &c.Cfg.MaxAttempts,
+			},
+			&cli.BoolFlag{
+				Name:        "retry-post",
+				Usage:       "also retry non idempotent requests such as POST (overwrites file and HS_RETRY_POST environment configs)",
+				Destination: &c.Cfg.RetryPost,
+			},
+			&cli.StringFlag{
+				Name:        "record",
+				Usage:       "record requests and responses, with credentials redacted, to a cassette file (overwrites file and HS_RECORD environment configs)",
+				Destination: &c.Cfg.Record,
+			},
+			&cli.StringFlag{
+				Name:        "replay",
+				Usage:       "answer requests from a cassette file recorded with --record, without any network (overwrites file and HS_REPLAY environment configs)",
+				Destination: &c.Cfg.Replay,
+			},
+			&cli.StringFlag{
+				Name:        "har",
+				Usage:       "write an HTTP Archive of every request and response, with timings, to a file (overwrites file and HS_HAR environment configs)",
+				Destination: &c.Cfg.HAR,
+			},
+			&cli.StringFlag{
+				Name:        "har-redact",
+				Usage:       "comma separated headers, cookies and JSON fields redacted in the HAR, \"none\" to keep everything (overwrites file and HS_HAR_REDACT environment configs) (default: credentials, cookies and password fields)",
+				Destination: &c.Cfg.HARRedact,
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "write the requests that would change something instead of sending them, reads are still sent (overwrites file and HS_DRY_RUN environment configs)",
+				Destination: &c.Cfg.DryRun,
+			},
+			&cli.StringFlag{
+				Name:        "explain",
+				Usage:       "dry run writing the requests as http messages or curl command lines, one of " + strings.Join(client.ExplainFormats, ", ") + " (overwrites file and HS_EXPLAIN environment configs)",
+				Destination: &c.Cfg.Explain,
+			},
 			&cli.BoolFlag{
 				Name:    "debug",
 				Aliases: []string{"d"},
-				Usage:   "log debug information to the console",
+				Usage:   "log debug information, same as --log-level debug",
+			},
+			&cli.StringFlag{
+				Name:        "log-level",
+				Usage:       "minimum level logged, one of debug, info, warn or error (overwrites file and HS_LOG_LEVEL environment configs) (default: info)",
+				Destination: &c.Cfg.LogLevel,
+			},
+			&cli.StringFlag{
+				Name:        "log-format",
+				Usage:       "format of the logs, text or json (overwrites file and HS_LOG_FORMAT environment configs) (default: text)",
+				Destination: &c.Cfg.LogFormat,
+			},
+			&cli.StringFlag{
+				Name:        "log-file",
+				Usage:       "append the logs to a file instead of stderr (overwrites file and HS_LOG_FILE environment configs)",
+				Destination: &c.Cfg.LogFile,
 			},
 		},
 		Commands: []*cli.Command{
@@ -82,10 +373,9 @@ func main() {
 				Usage:     "retrieve all members",
 				UsageText: "mgetall [command options]",
 				Action: func(cCtx *cli.Context) error {
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
 						commands.WithLoginRetry(
 							commands.GetMembers)))
-					return nil
 				},
 			},
 			{
@@ -94,13 +384,11 @@ func main() {
 				UsageText: "mget [command options] <username>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() == 0 {
-						fmt.Fprintf(os.Stderr, "Missing <username> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
 						commands.WithLoginRetry(
 							commands.GetMemberByUsername), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
@@ -108,42 +396,79 @@ func main() {
 				Usage:     "create member",
 				UsageText: "mcreate [commands options] [<file>]",
 				Action: func(cCtx *cli.Context) error {
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.CreateMember)), cCtx.Args().Slice()...))
-					return nil
+							commands.JournaledCreate("member", "username", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.CreateMember))), cCtx.Args().Slice()...))
 				},
 			},
 			{
 				Name:      "mupdate",
 				Usage:     "update member information",
-				UsageText: "mupdate [command options] <username> [<file>]",
+				UsageText: "mupdate [command options] <username> [<file>]\n   mupdate [--set key=value...] [--unset key...] [--merge-patch <file>] [--json-patch <file>] <username>",
+				Flags:     updateFlags(),
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing arguments\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing arguments", EX_USAGE)
+					}
+					if opts := patchOptions(cCtx); !opts.Empty() {
+						if cCtx.Args().Len() > 1 || cCtx.String("base") != "" {
+							return cli.Exit("A <file> or --base can't be given along with patch options", EX_USAGE)
+						}
+						return exit(commands.RunCommandColumns(c, commands.MemberColumns,
+							commands.WithLoginRetry(
+								commands.Journaled("member", commandLine(cCtx),
+									commands.PatchMember(opts))), cCtx.Args().Slice()...))
 					}
-					os.Exit(commands.RunCommand(c,
+					if base := cCtx.String("base"); base != "" {
+						return exit(commands.RunCommandColumns(c, commands.MemberColumns,
+							commands.WithLoginRetry(
+								commands.Journaled("member", commandLine(cCtx),
+									commands.DefaultLastArgumentToStdin(
+										commands.UpdateMemberFrom(base, cCtx.Bool("merge"))))), cCtx.Args().Slice()...))
+					}
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
+						commands.WithLoginRetry(
+							commands.Journaled("member", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.UpdateMember))), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:      "medit",
+				Usage:     "edit member information in $EDITOR, like kubectl edit",
+				UsageText: "medit [command options] <username>",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						return cli.Exit("Missing <username> argument", EX_USAGE)
+					}
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.UpdateMember)), cCtx.Args().Slice()...))
-					return nil
+							commands.Journaled("member", commandLine(cCtx),
+								commands.EditMember)), cCtx.Args().Slice()...))
 				},
 			},
 			{
 				Name:      "mdelete",
 				Usage:     "delete member from the database",
 				UsageText: "mdelete [command options] <username>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "delete without asking for confirmation",
+					},
+				},
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <username> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
-						commands.WithLoginRetry(
-							commands.DeleteMember), cCtx.Args().Slice()...))
-					return nil
+					return exit(commands.RunCommand(c,
+						commands.Unprotected("delete members",
+							commands.WithLoginRetry(
+								commands.Journaled("member", commandLine(cCtx),
+									commands.DeleteMember(cCtx.Bool("yes"))))), cCtx.Args().Slice()...))
 				},
 			},
 			{
@@ -152,13 +477,11 @@ func main() {
 				UsageText: "mprojects [command options] <username>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <username> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
 						commands.WithLoginRetry(
 							commands.GetMemberProjects), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
@@ -167,13 +490,11 @@ func main() {
 				UsageText: "mgetlogo [command options] <username>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <username> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
 							commands.GetMemberLogo), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
@@ -182,13 +503,11 @@ func main() {
 				UsageText: "mgetlogo [command options] <username>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() == 0 {
-						fmt.Fprintf(os.Stderr, "Missing <username> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
 							commands.GetTags), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
@@ -197,14 +516,13 @@ func main() {
 				UsageText: "maddproject [commands options] <username> <proj_name> [<file>]",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 2 {
-						fmt.Fprintf(os.Stderr, "Missing arguments\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing arguments", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.AddProject)), cCtx.Args().Slice()...))
-					return nil
+							commands.Journaled("member", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.AddProject))), cCtx.Args().Slice()...))
 				},
 			},
 			{
@@ -213,14 +531,13 @@ func main() {
 				UsageText: "mupdatelogo [command options] <username> [<logo>]",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <username> arguments\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> arguments", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.UpdateMemberLogo)), cCtx.Args().Slice()...))
-					return nil
+							commands.Journaled("member", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.UpdateMemberLogo))), cCtx.Args().Slice()...))
 				},
 			},
 			{
@@ -229,14 +546,13 @@ func main() {
 				UsageText: "maddtag [command options] <username> [<file>]",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <username> arguments\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> arguments", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.AddTag)), cCtx.Args().Slice()...))
-					return nil
+							commands.Journaled("member", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.AddTag))), cCtx.Args().Slice()...))
 				},
 			},
 			{
@@ -245,14 +561,38 @@ func main() {
 				UsageText: "mdeltag [command options] <username> [<file>]",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <username> arguments\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <username> arguments", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
-						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.DeleteTag)), cCtx.Args().Slice()...))
-					return nil
+					return exit(commands.RunCommand(c,
+						commands.Unprotected("delete tags",
+							commands.WithLoginRetry(
+								commands.Journaled("member", commandLine(cCtx),
+									commands.DefaultLastArgumentToStdin(
+										commands.DeleteTag)))), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:      "mimport",
+				Usage:     "create members from a CSV or JSONL file, adding their tags and projects",
+				UsageText: "mimport [command options] <members.csv|members.jsonl>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "mapping",
+						Aliases: []string{"m"},
+						Usage:   "YAML or JSON file mapping the columns of the file to member fields",
+					},
+					&cli.BoolFlag{
+						Name:  "continue-on-error",
+						Usage: "keep importing the remaining rows after a row fails",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						return cli.Exit("Missing <file> argument", EX_USAGE)
+					}
+					// rows log in again themselves, rerunning the whole import would create duplicates
+					return exit(commands.RunCommandColumns(c, commands.ImportColumns,
+						commands.ImportMembers(cCtx.String("mapping"), cCtx.Bool("continue-on-error"), commandLine(cCtx)), cCtx.Args().Slice()...))
 				},
 			},
 			{
@@ -260,10 +600,9 @@ func main() {
 				Usage:     "retrieve all projects",
 				UsageText: "pgetall [command options]",
 				Action: func(cCtx *cli.Context) error {
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
 						commands.WithLoginRetry(
 							commands.GetProjects)))
-					return nil
 				},
 			},
 			{
@@ -272,13 +611,11 @@ func main() {
 				UsageText: "pget [command options] <proj_name>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <proj_name> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <proj_name> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
 						commands.WithLoginRetry(
 							commands.GetProjectByID), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
@@ -286,42 +623,79 @@ func main() {
 				Usage:     "create a new project",
 				UsageText: "pcreate [command options] [<file>]",
 				Action: func(cCtx *cli.Context) error {
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.CreateProject)), cCtx.Args().Slice()...))
-					return nil
+							commands.JournaledCreate("project", "name", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.CreateProject))), cCtx.Args().Slice()...))
 				},
 			},
 			{
 				Name:      "pupdate",
 				Usage:     "update information of a project",
-				UsageText: "pupdate [command options] <proj_name> [<file>]",
+				UsageText: "pupdate [command options] <proj_name> [<file>]\n   pupdate [--set key=value...] [--unset key...] [--merge-patch <file>] [--json-patch <file>] <proj_name>",
+				Flags:     updateFlags(),
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing arguments\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing arguments", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					if opts := patchOptions(cCtx); !opts.Empty() {
+						if cCtx.Args().Len() > 1 || cCtx.String("base") != "" {
+							return cli.Exit("A <file> or --base can't be given along with patch options", EX_USAGE)
+						}
+						return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
+							commands.WithLoginRetry(
+								commands.Journaled("project", commandLine(cCtx),
+									commands.PatchProject(opts))), cCtx.Args().Slice()...))
+					}
+					if base := cCtx.String("base"); base != "" {
+						return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
+							commands.WithLoginRetry(
+								commands.Journaled("project", commandLine(cCtx),
+									commands.DefaultLastArgumentToStdin(
+										commands.UpdateProjectFrom(base, cCtx.Bool("merge"))))), cCtx.Args().Slice()...))
+					}
+					return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
 						commands.WithLoginRetry(
-							commands.DefaultLastArgumentToStdin(
-								commands.UpdateProject)), cCtx.Args().Slice()...))
-					return nil
+							commands.Journaled("project", commandLine(cCtx),
+								commands.DefaultLastArgumentToStdin(
+									commands.UpdateProject))), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:      "pedit",
+				Usage:     "edit information of a project in $EDITOR, like kubectl edit",
+				UsageText: "pedit [command options] <proj_name>",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						return cli.Exit("Missing <proj_name> argument", EX_USAGE)
+					}
+					return exit(commands.RunCommandColumns(c, commands.ProjectColumns,
+						commands.WithLoginRetry(
+							commands.Journaled("project", commandLine(cCtx),
+								commands.EditProject)), cCtx.Args().Slice()...))
 				},
 			},
 			{
 				Name:      "pdelete",
 				Usage:     "delete project from the database",
 				UsageText: "pdelete [command options] <proj_name> ",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "delete without asking for confirmation",
+					},
+				},
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <proj_name> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <proj_name> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
-						commands.WithLoginRetry(
-							commands.DeleteProject), cCtx.Args().Slice()...))
-					return nil
+					return exit(commands.RunCommand(c,
+						commands.Unprotected("delete projects",
+							commands.WithLoginRetry(
+								commands.Journaled("project", commandLine(cCtx),
+									commands.DeleteProject(cCtx.Bool("yes"))))), cCtx.Args().Slice()...))
 				},
 			},
 			{
@@ -330,13 +704,11 @@ func main() {
 				UsageText: "pmembers [command options] <proj_name>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <proj_name> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <proj_name> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
 						commands.WithLoginRetry(
 							commands.GetProjectMembers), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
@@ -345,69 +717,330 @@ func main() {
 				UsageText: "pmembers [command options] <proj_name>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 1 {
-						fmt.Fprintf(os.Stderr, "Missing <proj_name> argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing <proj_name> argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
 							commands.GetProjectLogo), cCtx.Args().Slice()...))
-					return nil
 				},
 			},
 			{
 				Name:      "paddmember",
 				Usage:     "add member to a project",
-				UsageText: "pmembers [command options] <proj_name> <username>",
+				UsageText: "paddmember [command options] <proj_name> <username>",
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() < 2 {
-						fmt.Fprintf(os.Stderr, "Missing argument\n")
-						os.Exit(EX_USAGE)
+						return cli.Exit("Missing argument", EX_USAGE)
 					}
-					os.Exit(commands.RunCommand(c,
+					return exit(commands.RunCommand(c,
 						commands.WithLoginRetry(
-							commands.GetProjectMembers), cCtx.Args().Slice()...))
-					return nil
+							commands.Journaled("project", commandLine(cCtx),
+								commands.AddMember)), cCtx.Args().Slice()...))
 				},
 			},
 			{
 				Name:  "login",
 				Usage: "login to the API, saving the cookie to the cookiejar",
 				Action: func(cCtx *cli.Context) error {
-					// Here we are simply making use of the way this "framework" is setup,
-					// instead of writting a new command (which would just result in code duplication)
-					// we simply pass it a fake command which returns Unauthorized at first and forces the
-					// decorator to attempt a login, if it can do it, then we just return successful
-					os.Exit(commands.RunCommand(c, commands.Login))
-					return nil
+					// logs in directly with the resolved password, saving it encrypted when encrypt is set
+					return exit(commands.RunCommand(c, commands.WithCredentialStore(commands.Login)))
 				},
 			},
 			{
 				Name:  "logout",
 				Usage: "logout off the API, clearing the session",
 				Action: func(cCtx *cli.Context) error {
-					os.Exit(commands.RunCommand(c,
-						func(c *client.Client, args ...string) ([]byte, error) {
-							rsp, err := c.Http.Get(c.Cfg.Root + "/logout")
-							if err != nil {
-								return nil, commands.NewCommandError("Failed requesting server", fmt.Errorf("http.Get %s: %w", c.Cfg.Root+"/logout", err))
-							}
-							defer rsp.Body.Close()
-							rspData, err := io.ReadAll(rsp.Body)
-							if err != nil {
-								return nil, commands.NewCommandError("Failed receiving server response", fmt.Errorf("io.ReadAll: %w", err))
+					return exit(commands.RunCommand(c, commands.Logout))
+				},
+			},
+			{
+				Name:      "plan",
+				Usage:     "show the changes needed to match the manifests",
+				UsageText: "plan [command options] -f <file|dir> [-f <file|dir>...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "manifest file or directory of manifests",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "prune",
+						Usage: "delete members and projects missing from the manifests",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					return exit(commands.RunCommandColumns(c, commands.ChangeColumns,
+						commands.WithLoginRetry(
+							commands.Plan(cCtx.Bool("prune"))), cCtx.StringSlice("file")...))
+				},
+			},
+			{
+				Name:      "apply",
+				Usage:     "apply the manifests of members and projects to the API",
+				UsageText: "apply [command options] -f <file|dir> [-f <file|dir>...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "manifest file or directory of manifests",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "prune",
+						Usage: "delete members and projects missing from the manifests",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "apply without asking for confirmation",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					cmd := commands.WithLoginRetry(commands.Apply(cCtx.Bool("prune"), cCtx.Bool("yes"), commandLine(cCtx)))
+					if cCtx.Bool("prune") {
+						cmd = commands.Unprotected("prune", cmd)
+					}
+					return exit(commands.RunCommandColumns(c, commands.ChangeColumns, cmd, cCtx.StringSlice("file")...))
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "export all members or projects to a CSV, JSONL or XLSX file written to stdout",
+				UsageText: "export [command options] members|projects",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: output.CSV,
+						Usage: "file format, one of " + strings.Join(output.ExportFormats, ", "),
+					},
+					&cli.StringSliceFlag{
+						Name:  "columns",
+						Usage: "columns to export, in order, e.g, username,name,tags (defaults to all)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "join",
+						Usage: "add the tags or projects of each member, or the members of each project",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						return cli.Exit("Missing members|projects argument", EX_USAGE)
+					}
+					if !slices.Contains(output.ExportFormats, cCtx.String("format")) {
+						return cli.Exit(fmt.Sprintf("Unknown format %s, expected one of %s", cCtx.String("format"), strings.Join(output.ExportFormats, ", ")), EX_USAGE)
+					}
+					return exit(commands.RunCommand(c,
+						commands.WithLoginRetry(
+							commands.Export(cCtx.String("format"), cCtx.StringSlice("columns"), cCtx.StringSlice("join"))), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:      "backup",
+				Usage:     "back up all members, projects, tags, memberships and logos",
+				UsageText: "backup [command options] <dir|archive.tar.gz>",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						return cli.Exit("Missing <dir|archive.tar.gz> argument", EX_USAGE)
+					}
+					return exit(commands.RunCommand(c,
+						commands.WithLoginRetry(
+							commands.Backup), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:      "restore",
+				Usage:     "restore a backup into the API",
+				UsageText: "restore [command options] <dir|archive.tar.gz>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "skip-existing",
+						Usage: "leave members and projects already in the API untouched",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						return cli.Exit("Missing <dir|archive.tar.gz> argument", EX_USAGE)
+					}
+					return exit(commands.RunCommand(c,
+						commands.Unprotected("restore",
+							commands.WithLoginRetry(
+								commands.Restore(cCtx.Bool("skip-existing"), commandLine(cCtx)))), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:      "migrate",
+				Usage:     "copy members, projects, tags, memberships and logos from one profile to another",
+				UsageText: "migrate [command options] --from <profile> --to <profile>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "profile to read from",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "profile to write to",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "skip-existing",
+						Usage: "leave members and projects already in the destination untouched",
+					},
+					&cli.BoolFlag{
+						Name:  "anonymise",
+						Usage: "replace names, emails, IST IDs, descriptions and logos of members with placeholders",
+					},
+					&cli.StringSliceFlag{
+						Name:  "map-username",
+						Usage: "rename a member, as `old=new`",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					return exit(commands.RunCommand(c,
+						commands.Migrate(commands.MigrateOptions{
+							From:         cCtx.String("from"),
+							To:           cCtx.String("to"),
+							SkipExisting: cCtx.Bool("skip-existing"),
+							Anonymise:    cCtx.Bool("anonymise"),
+							Usernames:    cCtx.StringSlice("map-username"),
+						})))
+				},
+			},
+			{
+				Name:      "whoami",
+				Usage:     "show the record of the configured user",
+				UsageText: "whoami [command options]",
+				Action: func(cCtx *cli.Context) error {
+					return exit(commands.RunCommandColumns(c, commands.MemberColumns,
+						commands.WithLoginRetry(
+							commands.WhoAmI)))
+				},
+			},
+			{
+				Name:  "dev",
+				Usage: "tools for developing and testing against the API",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "serve-fake",
+						Usage:     "serve an in-memory fake of the API",
+						UsageText: "dev serve-fake [command options]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "addr",
+								Value: "127.0.0.1:8080",
+								Usage: "address to listen on",
+							},
+							&cli.StringFlag{
+								Name:  "login",
+								Value: "admin:admin",
+								Usage: "username and password of the member to log in with, as `user:password`",
+							},
+							&cli.StringFlag{
+								Name:  "seed",
+								Usage: "backup directory or archive to load, see backup",
+							},
+							&cli.DurationFlag{
+								Name:  "session-ttl",
+								Value: time.Hour,
+								Usage: "lifetime of the session cookies",
+							},
+							&cli.BoolFlag{
+								Name:  "etags",
+								Usage: "send ETags and honour If-Match, like servers supporting conditional updates",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							username, password, ok := strings.Cut(cCtx.String("login"), ":")
+							if !ok || username == "" {
+								return cli.Exit("Invalid --login, expected user:password", EX_USAGE)
 							}
-							if rsp.StatusCode != http.StatusOK {
-								return nil, commands.NewCommandError(fmt.Sprintf("%d %s\n%s", rsp.StatusCode, http.StatusText(rsp.StatusCode), string(rspData)), nil)
+							err := commands.ServeFake(cCtx.String("addr"), username, password, cCtx.String("seed"), cCtx.Duration("session-ttl"), cCtx.Bool("etags"))
+							return cli.Exit(err.Error(), 2)
+						},
+					},
+				},
+			},
+			{
+				Name:      "undo",
+				Usage:     "restore the member or project changed by a journal entry, the last one not undone by default",
+				UsageText: "undo [command options] [<id>]",
+				Action: func(cCtx *cli.Context) error {
+					return exit(commands.RunCommand(c,
+						commands.Unprotected("undo",
+							commands.WithLoginRetry(
+								commands.Undo)), cCtx.Args().Slice()...))
+				},
+			},
+			{
+				Name:  "journal",
+				Usage: "inspect the journal of the changes made to members and projects, kept for undo",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "list",
+						Usage:     "list the changes with who made them, when, in which profile and with which command",
+						UsageText: "journal list [command options]",
+						Action: func(cCtx *cli.Context) error {
+							return exit(commands.RunCommandColumns(c, commands.JournalColumns, commands.JournalList))
+						},
+					},
+				},
+			},
+			{
+				Name:  "session",
+				Usage: "inspect the session kept in the cookie jar",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "show",
+						Usage:     "show the cookies of the configured root, with their values redacted",
+						UsageText: "session show [command options]",
+						Action: func(cCtx *cli.Context) error {
+							return exit(commands.RunCommandColumns(c, commands.CookieColumns, commands.ShowSession))
+						},
+					},
+					{
+						Name:      "clear",
+						Usage:     "remove the cookies of the configured root from the cookie jar",
+						UsageText: "session clear [command options]",
+						Action: func(cCtx *cli.Context) error {
+							return exit(commands.RunCommand(c, commands.ClearSession))
+						},
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "manage the configuration file",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "path",
+						Usage:     "show the configuration file in use and where each value came from (flag, env, file, profile or default)",
+						UsageText: "config path [command options]",
+						Action: func(cCtx *cli.Context) error {
+							return exit(commands.RunCommandColumns(c, commands.ConfigValueColumns, commands.ConfigPath))
+						},
+					},
+					{
+						Name:      "list-profiles",
+						Usage:     "list the profiles in the configuration file",
+						UsageText: "config list-profiles [command options]",
+						Action: func(cCtx *cli.Context) error {
+							return exit(commands.RunCommandColumns(c, commands.ProfileColumns, commands.ListProfiles))
+						},
+					},
+					{
+						Name:      "use-profile",
+						Usage:     "set the current profile of the configuration file",
+						UsageText: "config use-profile [command options] <profile>",
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() < 1 {
+								return cli.Exit("Missing <profile> argument", EX_USAGE)
 							}
-							return rspData, nil
-						}))
-					return nil
+							return exit(commands.RunCommand(c, commands.UseProfile, cCtx.Args().Slice()...))
+						},
+					},
 				},
 			},
 		},
 	}
-
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
-	}
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"hscli/client"
+	"hscli/fakeapi"
+	"hscli/journal"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden files in testdata/golden")
+
+// Far in the future, so sessions created by the fake API never expire during a test
+var fakeNow = time.Date(2100, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Runs of the program compared with testdata/golden/<name>.golden.
+// Every run of a case shares the same fake API and cookie jar, args are appended to the connection flags
+var goldenCases = []struct {
+	name string
+	runs [][]string
+}{
+	{"usage-missing-argument", [][]string{{"mget"}}},
+	{"usage-unknown-output", [][]string{{"-o", "xml", "mgetall"}}},
+	{"login", [][]string{{"login"}, {"session", "show"}, {"whoami"}}},
+	{"login-wrong-password", [][]string{{"-p", "wrong", "mgetall"}}},
+	{"logout", [][]string{{"login"}, {"logout"}, {"session", "show"}}},
+	{"session-clear", [][]string{{"login"}, {"session", "clear"}, {"session", "show"}}},
+
+	{"mgetall", [][]string{{"mgetall"}, {"-o", "table", "mgetall"}, {"-o", "yaml", "mgetall"}}},
+	{"mget", [][]string{{"mget", "john"}, {"mget", "nobody"}}},
+	{"mcreate", [][]string{{"mcreate", "testdata/member.json"}, {"mcreate", "testdata/member.json"}, {"mget", "alice"}}},
+	{"mupdate", [][]string{{"mupdate", "john", "testdata/member-update.json"}, {"mget", "john"}}},
+	{"mupdate-clear", [][]string{{"mupdate", "john", "testdata/member-clear.json"}, {"mget", "john"}}},
+	{"mupdate-patch", [][]string{{"mupdate", "--set", "name=John Doe", "--unset", "email", "john"}, {"mupdate", "--merge-patch", "testdata/member-merge-patch.json", "john"}, {"mupdate", "--set", "year=3", "john"}, {"mupdate", "--unset", "year", "john"}}},
+	{"mupdate-base", [][]string{{"mupdate", "--base", "testdata/member-base.json", "john", "testdata/member-edited.json"}, {"mupdate", "--base", "testdata/member-base.json", "--merge", "john", "testdata/member-edited.json"}}},
+	{"mupdate-base-empty", [][]string{{"mupdate", "john", "testdata/member-clear.json"}, {"mupdate", "--base", "testdata/member-base-empty.json", "john", "testdata/member-edited-empty.json"}}},
+	{"mdelete", [][]string{{"mdelete", "john"}, {"mdelete", "--yes", "john"}, {"mget", "john"}}},
+	{"protected", [][]string{{"-f", "testdata/protected.yaml", "--profile", "prod", "mdelete", "--yes", "jane"}, {"-f", "testdata/protected.yaml", "--profile", "prod", "apply", "--prune", "--yes", "-f", "testdata/manifest.yaml"}, {"-f", "testdata/protected.yaml", "--profile", "prod", "restore", "backup"}, {"-f", "testdata/protected.yaml", "--profile", "prod", "undo"}, {"-f", "testdata/protected.yaml", "migrate", "--from", "staging", "--to", "archive"}, {"mget", "jane"}}},
+	{"mprojects", [][]string{{"mprojects", "john"}, {"mprojects", "jane"}}},
+	{"mlogo", [][]string{{"mlogo", "john"}, {"mlogo", "jane"}}},
+	{"maddlogo", [][]string{{"maddlogo", "jane", "testdata/logo.png"}, {"mlogo", "jane"}}},
+	{"mtags", [][]string{{"mtags", "john"}}},
+	{"maddtag", [][]string{{"maddtag", "john", "testdata/tag.json"}, {"mtags", "john"}}},
+	{"mdeltag", [][]string{{"mdeltag", "john", "testdata/tag-dev.json"}, {"mtags", "john"}}},
+	{"medit", [][]string{{"medit", "john"}, {"mget", "john"}}},
+	{"medit-clear", [][]string{{"mupdate", "--set", "description=obsolete", "jane"}, {"medit", "jane"}, {"mget", "jane"}}},
+	{"maddproject", [][]string{{"maddproject", "jane", "web", "testdata/membership.json"}, {"mprojects", "jane"}}},
+	{"mimport", [][]string{{"mimport", "-m", "testdata/mapping.yaml", "--continue-on-error", "testdata/members.csv"}, {"mtags", "alice"}}},
+
+	{"pgetall", [][]string{{"pgetall"}, {"-o", "table", "pgetall"}}},
+	{"pget", [][]string{{"pget", "web"}, {"pget", "nothing"}}},
+	{"pcreate", [][]string{{"pcreate", "testdata/project.json"}, {"pget", "infra"}}},
+	{"pupdate", [][]string{{"pupdate", "web", "testdata/project-update.json"}, {"pget", "web"}}},
+	{"pupdate-patch", [][]string{{"pupdate", "--json-patch", "testdata/project-json-patch.json", "web"}, {"pupdate", "--json-patch", "testdata/project-json-patch.json", "web"}}},
+	{"pdelete", [][]string{{"pdelete", "web"}, {"pdelete", "--yes", "web"}, {"pgetall"}, {"mprojects", "john"}}},
+	{"pmembers", [][]string{{"pmembers", "web"}}},
+	{"plogo", [][]string{{"plogo", "web"}}},
+	{"pedit", [][]string{{"pedit", "web"}, {"pupdate", "--set", "description=John's site", "web"}, {"pedit", "web"}, {"pget", "web"}}},
+	{"paddmember", [][]string{{"paddmember", "web", "jane"}, {"pmembers", "web"}}},
+
+	{"plan", [][]string{{"-o", "table", "plan", "-f", "testdata/manifest.yaml"}}},
+	{"apply", [][]string{{"apply", "--yes", "-f", "testdata/manifest.yaml"}, {"plan", "-f", "testdata/manifest.yaml"}, {"mtags", "john"}, {"mget", "john"}}},
+	{"apply-prune-projects", [][]string{{"plan", "-f", "testdata/manifest-memberships.yaml"}, {"apply", "--prune", "--yes", "-f", "testdata/manifest-memberships.yaml"}, {"mprojects", "john"}, {"mprojects", "jane"}, {"undo"}, {"undo"}, {"mprojects", "john"}, {"mprojects", "jane"}}},
+	{"replay", [][]string{{"--replay", "testdata/mget-john.cassette.json", "mget", "john"}, {"--replay", "testdata/mget-john.cassette.json", "mget", "jane"}}},
+	{"dry-run", [][]string{{"--dry-run", "mdelete", "john"}, {"--explain", "curl", "maddlogo", "jane", "testdata/logo.png"}, {"--explain", "curl", "mupdate", "--set", "name=Jane Doe", "jane"}, {"mget", "jane"}}},
+	{"undo-bulk", [][]string{{"apply", "--prune", "--yes", "-f", "testdata/manifest.yaml"}, {"undo"}, {"restore", "testdata/backup"}, {"-o", "table", "journal", "list"}, {"undo"}, {"undo"}, {"mget", "john"}, {"undo", "2"}, {"mget", "john"}}},
+	{"restore-partial", [][]string{{"restore", "testdata/backup-missing-project"}, {"mprojects", "jane"}}},
+	{"undo", [][]string{{"mupdate", "--set", "name=Johnny", "--unset", "email", "john"}, {"mdelete", "--yes", "jane"}, {"undo"}, {"undo"}, {"mget", "john"}, {"mget", "jane"}, {"undo", "3"}, {"mget", "jane"}, {"undo", "1"}, {"-o", "table", "journal", "list"}}},
+	{"undo-added", [][]string{{"maddproject", "jane", "web", "testdata/membership.json"}, {"maddtag", "jane", "testdata/tag.json"}, {"mcreate", "testdata/member.json"}, {"undo"}, {"undo"}, {"undo"}, {"mprojects", "jane"}, {"mtags", "jane"}, {"mget", "alice"}}},
+	{"export", [][]string{{"export", "--join", "tags,projects", "members"}, {"export", "--format", "jsonl", "--columns", "name,members", "--join", "members", "projects"}, {"pdelete", "--yes", "legacy"}, {"-o", "yaml", "export", "--format", "jsonl", "--columns", "name,state", "projects"}}},
+}
+
+// Set when the test binary is run as the editor of medit and pedit, see fakeEditor
+const fakeEditorEnv = "HSCLI_TEST_EDITOR"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeEditorEnv) != "" {
+		os.Exit(fakeEditor(os.Args[1]))
+	}
+	flag.Parse()
+	log.SetFlags(0) // no timestamps in the logs written to stderr
+	os.Exit(m.Run())
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url, dir := setupGolden(t)
+			connection := []string{"hscli", "-r", url, "-u", "admin", "-p", "secret", "-c", filepath.Join(dir, "cookiejar.json")}
+
+			var got bytes.Buffer
+			for _, args := range tc.runs {
+				var stdout, stderr bytes.Buffer
+				code := run(append(connection, args...), &stdout, &stderr)
+				fmt.Fprintf(&got, "$ hscli %s\n", strings.Join(args, " "))
+				fmt.Fprintf(&got, "exit: %d\n-- stdout --\n%s\n-- stderr --\n%s\n", code, stdout.String(), stderr.String())
+			}
+			actual := strings.NewReplacer(url, "http://fakeapi", dir, "$TMP").Replace(got.String())
+
+			path := filepath.Join("testdata", "golden", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%s, run with -update to create it", err)
+			}
+			if actual != string(want) {
+				t.Errorf("output differs from %s, run with -update to accept it\n--- got ---\n%s\n--- want ---\n%s", path, actual, want)
+			}
+		})
+	}
+}
+
+// Edits the file at path like a user would: renames John to Johnny and clears the values "obsolete".
+// Writes a line to stderr, to show the editor runs with the streams of the commands
+func fakeEditor(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	edited := strings.NewReplacer("John", "Johnny", `"obsolete"`, `""`).Replace(string(data))
+	if err := os.WriteFile(path, []byte(edited), 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "edited")
+	return 0
+}
+
+// Starts a fake API with some members and projects, and isolates the program from the user's configuration.
+// Returns the URL of the API and a temporary directory
+func setupGolden(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, "HS_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "'"+strings.ReplaceAll(os.Args[0], "'", `'\''`)+"'") // used by medit and pedit, see fakeEditor
+	t.Setenv(fakeEditorEnv, "1")
+
+	journal.Now = func() time.Time { return fakeNow }
+
+	s := fakeapi.New()
+	s.Now = func() time.Time { return fakeNow }
+	s.AddMember(client.Member{Username: "admin", Name: "Admin"}, "secret")
+	s.AddMember(client.Member{Username: "john", Name: "John", Email: "john@example.com", MemberNumber: 42}, "john-pw")
+	s.AddMember(client.Member{Username: "jane", Name: "Jane", Course: "MEEC"}, "jane-pw")
+	s.AddProject(client.Project{Name: "web", State: "active", StartDate: "2023-09-01"})
+	s.AddProject(client.Project{Name: "legacy", State: "archived"})
+	s.AddTag("john", "dev")
+	s.AddMembership("john", "web", client.Membership{EntryDate: "2023-09-01"})
+	s.SetLogo("members", "john", "image/png", []byte("\x89PNG\r\n\x1a\njohn"))
+	s.SetLogo("projects", "web", "image/png", []byte("\x89PNG\r\n\x1a\nweb"))
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return srv.URL, dir
+}
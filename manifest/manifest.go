@@ -0,0 +1,198 @@
+// Declarative description of members and projects, see Plan and Change.Apply
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Desired state of the API, read from one or more manifest files
+type Manifest struct {
+	Members  []Member  `json:"members"`
+	Projects []Project `json:"projects"`
+}
+
+type Member struct {
+	Fields   map[string]any // member record fields, as sent to the API
+	Tags     []string       // nil if the tags aren't managed
+	Projects []Membership
+	Logo     string // logo file path, relative paths are resolved from the manifest
+}
+
+type Project struct {
+	Fields map[string]any
+	Logo   string
+}
+
+// Project membership, either a project name or an object with the membership fields
+type Membership struct {
+	Project string         `json:"name"`
+	Fields  map[string]any `json:"-"`
+}
+
+func (m *Member) Username() string {
+	s, _ := m.Fields["username"].(string)
+	return s
+}
+
+func (p *Project) Name() string {
+	s, _ := p.Fields["name"].(string)
+	return s
+}
+
+func (m *Member) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Tags     []string     `json:"tags"`
+		Projects []Membership `json:"projects"`
+		Logo     string       `json:"logo"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &m.Fields); err != nil {
+		return err
+	}
+	delete(m.Fields, "tags")
+	delete(m.Fields, "projects")
+	delete(m.Fields, "logo")
+	m.Tags, m.Projects, m.Logo = raw.Tags, raw.Projects, raw.Logo
+	return nil
+}
+
+func (p *Project) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Logo string `json:"logo"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &p.Fields); err != nil {
+		return err
+	}
+	delete(p.Fields, "logo")
+	p.Logo = raw.Logo
+	return nil
+}
+
+func (ms *Membership) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		ms.Project = name
+		return nil
+	}
+	if err := json.Unmarshal(data, &ms.Fields); err != nil {
+		return err
+	}
+	ms.Project, _ = ms.Fields["name"].(string)
+	delete(ms.Fields, "name")
+	return nil
+}
+
+// Reads manifests from files or directories, directories are read recursively for .yaml, .yml and .json files
+func Load(paths ...string) (*Manifest, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".yaml", ".yml", ".json":
+				if !d.IsDir() {
+					files = append(files, p)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := &Manifest{}
+	for _, file := range files {
+		f, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		m.Members = append(m.Members, f.Members...)
+		m.Projects = append(m.Projects, f.Projects...)
+	}
+	return m, m.validate()
+}
+
+func loadFile(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// YAML is a superset of JSON, going through JSON lets the records use the API field names
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range m.Members {
+		m.Members[i].Logo = resolve(dir, m.Members[i].Logo)
+	}
+	for i := range m.Projects {
+		m.Projects[i].Logo = resolve(dir, m.Projects[i].Logo)
+	}
+	return &m, nil
+}
+
+func resolve(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// Checks for missing and duplicated usernames and project names
+func (m *Manifest) validate() error {
+	var usernames, names []string
+	for _, member := range m.Members {
+		username := member.Username()
+		if username == "" {
+			return fmt.Errorf("member without username in manifest")
+		}
+		if slices.Contains(usernames, username) {
+			return fmt.Errorf("member %s declared more than once", username)
+		}
+		usernames = append(usernames, username)
+	}
+	for _, project := range m.Projects {
+		name := project.Name()
+		if name == "" {
+			return fmt.Errorf("project without name in manifest")
+		}
+		if slices.Contains(names, name) {
+			return fmt.Errorf("project %s declared more than once", name)
+		}
+		names = append(names, name)
+	}
+	return nil
+}
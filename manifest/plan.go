@@ -0,0 +1,360 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hscli/client"
+	"hscli/patch"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	ActionCreate        = "create"
+	ActionUpdate        = "update"
+	ActionDelete        = "delete"
+	ActionAddTag        = "add-tag"
+	ActionRemoveTag     = "remove-tag"
+	ActionAddProject    = "add-project"
+	ActionRemoveProject = "remove-project"
+	ActionUploadLogo    = "upload-logo"
+)
+
+const (
+	KindMember  = "member"
+	KindProject = "project"
+)
+
+// Never returned by the API, only sent when creating members
+const passwordField = "password"
+
+// Change needed to bring the API to the state described by a manifest
+type Change struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+
+	apply func(c *client.Client) error
+}
+
+// Human readable form, e.g. "~ update member john (name, email)"
+func (ch Change) String() string {
+	symbol := "~"
+	switch ch.Action {
+	case ActionCreate, ActionAddTag, ActionAddProject:
+		symbol = "+"
+	case ActionDelete, ActionRemoveTag, ActionRemoveProject:
+		symbol = "-"
+	}
+	s := fmt.Sprintf("%s %s %s %s", symbol, ch.Action, ch.Kind, ch.Name)
+	if ch.Detail != "" {
+		s += " (" + ch.Detail + ")"
+	}
+	return s
+}
+
+func (ch Change) Apply(c *client.Client) error {
+	return ch.apply(c)
+}
+
+// Applies the changes in order, stopping at the first failure.
+// The changes are applied through journal, unless it's nil, e.g. to record what they replace.
+// Returns the changes applied
+func Apply(c *client.Client, changes []Change, journal func(kind, name string, change func() error) error) ([]Change, error) {
+	for i, ch := range changes {
+		var err error
+		if journal != nil {
+			err = journal(ch.Kind, ch.Name, func() error { return ch.Apply(c) })
+		} else {
+			err = ch.Apply(c)
+		}
+		if err != nil {
+			return changes[:i], fmt.Errorf("%s: %w", ch, err)
+		}
+	}
+	return changes, nil
+}
+
+// Diffs the manifest against the API and returns the changes needed, in the order they must be applied.
+// Only the fields declared in the manifest are compared, tags and projects are only managed for members declaring them.
+// With prune, members and projects missing from the manifest are deleted, and members are removed from the projects they don't declare.
+// Otherwise projects are only added to members
+func Plan(c *client.Client, m *Manifest, prune bool) ([]Change, error) {
+	var projectChanges, memberChanges, deletes []Change
+
+	liveProjects, err := c.Projects.List()
+	if err != nil {
+		return nil, err
+	}
+	liveProjectsByName := map[string]client.Project{}
+	for _, p := range liveProjects {
+		liveProjectsByName[p.Name] = p
+	}
+	for _, p := range m.Projects {
+		changes, err := planProject(c, p, liveProjectsByName)
+		if err != nil {
+			return nil, err
+		}
+		projectChanges = append(projectChanges, changes...)
+	}
+
+	liveMembers, err := c.Members.List()
+	if err != nil {
+		return nil, err
+	}
+	liveMembersByName := map[string]client.Member{}
+	for _, member := range liveMembers {
+		liveMembersByName[member.Username] = member
+	}
+	for _, member := range m.Members {
+		changes, err := planMember(c, member, liveMembersByName, prune)
+		if err != nil {
+			return nil, err
+		}
+		memberChanges = append(memberChanges, changes...)
+	}
+
+	if prune {
+		for _, live := range liveMembers {
+			if !slices.ContainsFunc(m.Members, func(member Member) bool { return member.Username() == live.Username }) {
+				deletes = append(deletes, deleteMember(live.Username))
+			}
+		}
+		for _, live := range liveProjects {
+			if !slices.ContainsFunc(m.Projects, func(p Project) bool { return p.Name() == live.Name }) {
+				deletes = append(deletes, deleteProject(live.Name))
+			}
+		}
+	}
+
+	return slices.Concat(projectChanges, memberChanges, deletes), nil
+}
+
+func planProject(c *client.Client, p Project, live map[string]client.Project) ([]Change, error) {
+	var changes []Change
+	name := p.Name()
+	current, exists := live[name]
+	if !exists {
+		changes = append(changes, Change{
+			Action: ActionCreate, Kind: KindProject, Name: name,
+			apply: func(c *client.Client) error {
+				_, err := c.Projects.Create(p.Fields)
+				return err
+			},
+		})
+	} else if diff, payload, err := diffFields(p.Fields, current); err != nil {
+		return nil, err
+	} else if len(diff) > 0 {
+		changes = append(changes, Change{
+			Action: ActionUpdate, Kind: KindProject, Name: name, Detail: strings.Join(diff, ", "),
+			apply: func(c *client.Client) error {
+				_, err := c.Projects.Update(name, payload)
+				return err
+			},
+		})
+	}
+
+	if p.Logo != "" {
+		changed, err := logoChanged(p.Logo, exists, func() ([]byte, error) { return c.Projects.Logo(name) })
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, Change{
+				Action: ActionUploadLogo, Kind: KindProject, Name: name, Detail: p.Logo,
+				apply: func(c *client.Client) error {
+					return uploadLogo(p.Logo, func(f *os.File) error { return c.Projects.UploadLogo(name, p.Logo, f) })
+				},
+			})
+		}
+	}
+	return changes, nil
+}
+
+func planMember(c *client.Client, m Member, live map[string]client.Member, prune bool) ([]Change, error) {
+	var changes []Change
+	username := m.Username()
+	current, exists := live[username]
+	if !exists {
+		changes = append(changes, Change{
+			Action: ActionCreate, Kind: KindMember, Name: username,
+			apply: func(c *client.Client) error {
+				_, err := c.Members.Create(m.Fields)
+				return err
+			},
+		})
+	} else if diff, payload, err := diffFields(m.Fields, current); err != nil {
+		return nil, err
+	} else if len(diff) > 0 {
+		changes = append(changes, Change{
+			Action: ActionUpdate, Kind: KindMember, Name: username, Detail: strings.Join(diff, ", "),
+			apply: func(c *client.Client) error {
+				_, err := c.Members.Update(username, payload)
+				return err
+			},
+		})
+	}
+
+	if m.Tags != nil {
+		var liveTags []string
+		if exists {
+			tags, err := c.Members.Tags(username)
+			if err != nil {
+				return nil, err
+			}
+			liveTags = tags
+		}
+		for _, tag := range m.Tags {
+			if !slices.Contains(liveTags, tag) {
+				changes = append(changes, Change{
+					Action: ActionAddTag, Kind: KindMember, Name: username, Detail: tag,
+					apply: func(c *client.Client) error { return c.Members.AddTag(username, tag) },
+				})
+			}
+		}
+		for _, tag := range liveTags {
+			if !slices.Contains(m.Tags, tag) {
+				changes = append(changes, Change{
+					Action: ActionRemoveTag, Kind: KindMember, Name: username, Detail: tag,
+					apply: func(c *client.Client) error { return c.Members.DeleteTag(username, tag) },
+				})
+			}
+		}
+	}
+
+	if m.Projects != nil {
+		var liveProjects []client.Project
+		if exists {
+			projects, err := c.Members.Projects(username)
+			if err != nil {
+				return nil, err
+			}
+			liveProjects = projects
+		}
+		for _, ms := range m.Projects {
+			if slices.ContainsFunc(liveProjects, func(p client.Project) bool { return p.Name == ms.Project }) {
+				continue
+			}
+			changes = append(changes, Change{
+				Action: ActionAddProject, Kind: KindMember, Name: username, Detail: ms.Project,
+				apply: func(c *client.Client) error {
+					var membership client.Membership
+					if err := convert(ms.Fields, &membership); err != nil {
+						return err
+					}
+					return c.Members.AddProject(username, ms.Project, &membership)
+				},
+			})
+		}
+		for _, live := range liveProjects {
+			if !prune || slices.ContainsFunc(m.Projects, func(ms Membership) bool { return ms.Project == live.Name }) {
+				continue
+			}
+			changes = append(changes, Change{
+				Action: ActionRemoveProject, Kind: KindMember, Name: username, Detail: live.Name,
+				apply: func(c *client.Client) error { return c.Members.RemoveProject(username, live.Name) },
+			})
+		}
+	}
+
+	if m.Logo != "" {
+		changed, err := logoChanged(m.Logo, exists, func() ([]byte, error) { return c.Members.Logo(username) })
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, Change{
+				Action: ActionUploadLogo, Kind: KindMember, Name: username, Detail: m.Logo,
+				apply: func(c *client.Client) error {
+					return uploadLogo(m.Logo, func(f *os.File) error { return c.Members.UploadLogo(username, m.Logo, f) })
+				},
+			})
+		}
+	}
+	return changes, nil
+}
+
+func deleteMember(username string) Change {
+	return Change{
+		Action: ActionDelete, Kind: KindMember, Name: username,
+		apply: func(c *client.Client) error { return c.Members.Delete(username) },
+	}
+}
+
+func deleteProject(name string) Change {
+	return Change{
+		Action: ActionDelete, Kind: KindProject, Name: name,
+		apply: func(c *client.Client) error { return c.Projects.Delete(name) },
+	}
+}
+
+// Compares the declared fields with the live record, a field it omits is empty.
+// Returns the names of the fields that differ and the live record with the declared fields applied, sent as is so empty values clear fields
+func diffFields(declared map[string]any, live any) ([]string, map[string]any, error) {
+	var payload map[string]any
+	if err := convert(live, &payload); err != nil {
+		return nil, nil, err
+	}
+	var diff []string
+	for key, value := range declared {
+		if key == passwordField {
+			continue
+		}
+		current, ok := payload[key]
+		if !ok {
+			current = patch.Zero(value)
+		}
+		if !reflect.DeepEqual(current, value) {
+			diff = append(diff, key)
+		}
+		payload[key] = value
+	}
+	slices.Sort(diff)
+	return diff, payload, nil
+}
+
+// Whether the logo file differs from the live one, live is only called if the entity exists
+func logoChanged(path string, exists bool, live func() ([]byte, error)) (bool, error) {
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return true, nil
+	}
+	have, err := live()
+	if errors.Is(err, client.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(want, have), nil
+}
+
+func uploadLogo(path string, upload func(f *os.File) error) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return upload(f)
+}
+
+// Converts between JSON compatible values, e.g. a map into a client.Member
+func convert(from any, to any) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := json.Unmarshal(data, to); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	JSONL = "jsonl"
+	XLSX  = "xlsx"
+)
+
+var ExportFormats = []string{CSV, JSONL, XLSX}
+
+// Writes records to w as a spreadsheet-friendly file, keeping only the given columns in that order.
+// Text cells of CSV and XLSX files that a spreadsheet could take for a formula are escaped, see escapeFormula
+func Export(w io.Writer, records []map[string]any, cols []string, format string) error {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		cw.Write(cols)
+		for _, r := range records {
+			row := make([]string, len(cols))
+			for i, c := range cols {
+				row[i] = exportCell(r[c])
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	case JSONL:
+		for _, r := range records {
+			var line bytes.Buffer
+			line.WriteByte('{')
+			for i, c := range cols {
+				if i > 0 {
+					line.WriteByte(',')
+				}
+				key, err := json.Marshal(c)
+				if err != nil {
+					return err
+				}
+				value, err := json.Marshal(r[c])
+				if err != nil {
+					return err
+				}
+				line.Write(key)
+				line.WriteByte(':')
+				line.Write(value)
+			}
+			line.WriteString("}\n")
+			if _, err := w.Write(line.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case XLSX:
+		rows := make([][]any, len(records))
+		for i, r := range records {
+			rows[i] = make([]any, len(cols))
+			for j, c := range cols {
+				if n, ok := r[c].(float64); ok {
+					rows[i][j] = n
+				} else {
+					rows[i][j] = exportCell(r[c])
+				}
+			}
+		}
+		return writeXLSX(w, cols, rows)
+	}
+	return fmt.Errorf("unknown export format %q, expected one of %s", format, strings.Join(ExportFormats, ", "))
+}
+
+// Formats a JSON value as a cell of an exported file, numbers are kept as they are and text is escaped
+func exportCell(v any) string {
+	if _, ok := v.(float64); ok {
+		return cell(v)
+	}
+	return escapeFormula(cell(v))
+}
+
+// Prefixes text starting like a formula with ', so spreadsheets show it rather than evaluate it, e.g. =HYPERLINK(...) in a name.
+// The characters are the ones OWASP lists for CSV injection
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+)
+
+var exportRecords = []map[string]any{
+	{"username": "john", "name": "=1+2", "member_number": 42.0, "tags": []any{"-x", "dev"}},
+	{"username": "@jane", "member_number": -3.0, "name": "\tJane", "tags": []any{"\rdev"}},
+}
+
+var exportColumns = []string{"member_number", "username", "name", "tags"}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{CSV, "member_number,username,name,tags\n42,john,'=1+2,\"'-x,dev\"\n-3,'@jane,'\tJane,\"'\rdev\"\n"},
+		{JSONL, `{"member_number":42,"username":"john","name":"=1+2","tags":["-x","dev"]}` + "\n" +
+			`{"member_number":-3,"username":"@jane","name":"\tJane","tags":["\rdev"]}` + "\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Export(&buf, exportRecords, exportColumns, tc.format); err != nil {
+				t.Fatalf("Export: %s", err)
+			}
+			if buf.String() != tc.want {
+				t.Errorf("Export = %q, want %q", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportRecords, exportColumns, XLSX); err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %s", err)
+	}
+
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open %s: %s", f.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("ReadAll %s: %s", f.Name, err)
+		}
+		parts[f.Name] = data
+	}
+	for _, part := range xlsxParts {
+		if _, ok := parts[part.name]; !ok {
+			t.Errorf("missing part %s", part.name)
+		}
+	}
+
+	cell := func(ref, text string) string {
+		return `<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + text + `</t></is></c>`
+	}
+	want := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1">` + cell("A1", "member_number") + cell("B1", "username") + cell("C1", "name") + cell("D1", "tags") + `</row>` +
+		`<row r="2"><c r="A2"><v>42</v></c>` + cell("B2", "john") + cell("C2", "&#39;=1+2") + cell("D2", "&#39;-x,dev") + `</row>` +
+		`<row r="3"><c r="A3"><v>-3</v></c>` + cell("B3", "&#39;@jane") + cell("C3", "&#39;&#x9;Jane") + cell("D3", "&#39;&#xD;dev") + `</row>` +
+		`</sheetData></worksheet>`
+	if got := string(parts["xl/worksheets/sheet1.xml"]); got != want {
+		t.Errorf("sheet1.xml = %s\nwant %s", got, want)
+	}
+}
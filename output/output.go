@@ -0,0 +1,201 @@
+// Rendering of command results in the format selected with --output
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	JSON       = "json"
+	JSONPretty = "json-pretty"
+	YAML       = "yaml"
+	CSV        = "csv"
+	Table      = "table"
+	Template   = "template"
+)
+
+var Formats = []string{JSON, JSONPretty, YAML, CSV, Table, Template + "=<go-template>"}
+
+// Output format, e.g. "table" or "template={{.username}}"
+type Format struct {
+	Name     string
+	Template *template.Template // set when Name is Template
+}
+
+// Parses a --output value, an empty value defaults to JSON
+func ParseFormat(s string) (Format, error) {
+	name, arg, _ := strings.Cut(s, "=")
+	switch name {
+	case "":
+		return Format{Name: JSON}, nil
+	case JSON, JSONPretty, YAML, CSV, Table:
+		return Format{Name: name}, nil
+	case Template:
+		if arg == "" {
+			return Format{}, fmt.Errorf("missing template in %q, expected template=<go-template>", s)
+		}
+		tmpl, err := template.New("output").Funcs(funcs).Parse(arg)
+		if err != nil {
+			return Format{}, fmt.Errorf("invalid template: %w", err)
+		}
+		return Format{Name: Template, Template: tmpl}, nil
+	}
+	return Format{}, fmt.Errorf("unknown output format %q, expected one of %s", s, strings.Join(Formats, ", "))
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": func(sep string, v []any) string {
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i] = cell(e)
+		}
+		return strings.Join(s, sep)
+	},
+}
+
+// Writes a command result to w in the given format.
+// cols are the columns of the tabular formats, all the keys of the records sorted if empty.
+// Results that aren't JSON (e.g. logos) are written as is
+func Render(w io.Writer, data []byte, f Format, cols []string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		_, err := w.Write(data)
+		return err
+	}
+
+	switch f.Name {
+	case JSONPretty:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := w.Write(buf.Bytes())
+		return err
+	case YAML:
+		// JSON is valid YAML, decoding it again as YAML keeps integers as such
+		var y any
+		if err := yaml.Unmarshal(data, &y); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(y); err != nil {
+			return err
+		}
+		return enc.Close()
+	case CSV:
+		cols, rows := tabulate(v, cols)
+		cw := csv.NewWriter(w)
+		cw.Write(cols)
+		cw.WriteAll(rows)
+		return cw.Error()
+	case Table:
+		cols, rows := tabulate(v, cols)
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		header := make([]string, len(cols))
+		for i, c := range cols {
+			header[i] = strings.ToUpper(c)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case Template:
+		return f.Template.Execute(w, v)
+	}
+	_, err := fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// Turns a JSON value into a header and rows of cells, see columns
+func tabulate(v any, cols []string) ([]string, [][]string) {
+	var records []map[string]any
+	switch v := v.(type) {
+	case map[string]any:
+		records = []map[string]any{v}
+	case []any:
+		for _, e := range v {
+			obj, ok := e.(map[string]any)
+			if !ok {
+				obj = map[string]any{"value": e}
+			}
+			records = append(records, obj)
+		}
+	default:
+		records = []map[string]any{{"value": v}}
+	}
+
+	cols = columns(records, cols)
+	rows := make([][]string, len(records))
+	for i, r := range records {
+		rows[i] = make([]string, len(cols))
+		for j, c := range cols {
+			rows[i][j] = cell(r[c])
+		}
+	}
+	return cols, rows
+}
+
+// Columns of the records: cols, unless none of the records has any of them, e.g. plain values, otherwise all their keys sorted
+func columns(records []map[string]any, cols []string) []string {
+	if len(records) == 0 {
+		return []string{"value"}
+	}
+	if len(cols) > 0 && slices.ContainsFunc(records, func(r map[string]any) bool {
+		return slices.ContainsFunc(cols, func(c string) bool { _, ok := r[c]; return ok })
+	}) {
+		return cols
+	}
+	cols = nil
+	for _, r := range records {
+		for k := range r {
+			if !slices.Contains(cols, k) {
+				cols = append(cols, k)
+			}
+		}
+	}
+	slices.Sort(cols)
+	return cols
+}
+
+// Formats a JSON value as a single table cell
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i] = cell(e)
+		}
+		return strings.Join(s, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
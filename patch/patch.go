@@ -0,0 +1,344 @@
+// JSON Merge Patch (RFC 7386) and JSON Patch (RFC 6902) of decoded JSON documents
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Applies a merge patch to a document, null values in the patch remove members.
+// The document isn't modified, the patched copy is returned
+func Merge(doc, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	target, ok := doc.(map[string]any)
+	if !ok {
+		target = map[string]any{}
+	}
+	merged := make(map[string]any, len(target))
+	for k, v := range target {
+		merged[k] = v
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = Merge(merged[k], v)
+		}
+	}
+	return merged
+}
+
+// Three-way merge of the members of two objects derived from base, mine and theirs.
+// Members changed on one side only take that side, the names of those changed differently on both are returned as conflicts
+func Merge3(base, mine, theirs map[string]any) (map[string]any, []string) {
+	merged := map[string]any{}
+	var conflicts []string
+	keys := map[string]bool{}
+	for _, obj := range []map[string]any{base, mine, theirs} {
+		for k := range obj {
+			keys[k] = true
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		b, inBase := base[k]
+		m, inMine := mine[k]
+		t, inTheirs := theirs[k]
+		same := func(v1 any, in1 bool, v2 any, in2 bool) bool { return in1 == in2 && reflect.DeepEqual(v1, v2) }
+		switch {
+		case same(m, inMine, b, inBase):
+			if inTheirs {
+				merged[k] = t
+			}
+		case same(t, inTheirs, b, inBase), same(m, inMine, t, inTheirs):
+			if inMine {
+				merged[k] = m
+			}
+		default:
+			conflicts = append(conflicts, k)
+		}
+	}
+	return merged, conflicts
+}
+
+// Adds the members of current that fields lacks to fields as empty values, e.g. the zero values a record type omits.
+// Sending the result replaces current with fields, instead of only updating the members fields has
+func Replace(fields, current map[string]any) map[string]any {
+	for k, v := range current {
+		if _, ok := fields[k]; !ok {
+			fields[k] = Zero(v)
+		}
+	}
+	return fields
+}
+
+// Empty value of the JSON type of v, nil for null
+func Zero(v any) any {
+	switch v.(type) {
+	case string:
+		return ""
+	case float64:
+		return 0.0
+	case bool:
+		return false
+	case []any:
+		return []any{}
+	case map[string]any:
+		return map[string]any{}
+	}
+	return nil
+}
+
+// Operation of a JSON Patch
+type Operation struct {
+	Op    string `json:"op"`             // add, remove, replace, move, copy or test
+	Path  string `json:"path"`           // JSON Pointer to the target, e.g. /tags/0
+	From  string `json:"from,omitempty"` // source of move and copy
+	Value any    `json:"value,omitempty"`
+}
+
+// Applies the operations of a JSON Patch in order, failing on the first one that can't be applied.
+// The document isn't modified, the patched copy is returned
+func Apply(doc any, ops []Operation) (any, error) {
+	doc = clone(doc)
+	var err error
+	for i, op := range ops {
+		if doc, err = apply(doc, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc any, op Operation) (any, error) {
+	switch op.Op {
+	case "add":
+		return add(doc, op.Path, clone(op.Value))
+	case "remove":
+		doc, _, err := remove(doc, op.Path)
+		return doc, err
+	case "replace":
+		doc, _, err := remove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, clone(op.Value))
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("can't move %s into itself", op.From)
+		}
+		doc, v, err := remove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, v)
+	case "copy":
+		v, err := get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, clone(v))
+	case "test":
+		v, err := get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(v, op.Value) {
+			return nil, fmt.Errorf("test failed, value is %s", encode(v))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// Reads a JSON Patch, a JSON array of operations, from a file
+func ReadOperations(path string) ([]Operation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ops []Operation
+	var raw []map[string]json.RawMessage // tells a null value from a missing one
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("invalid JSON Patch: %w", err)
+	}
+	json.Unmarshal(data, &raw)
+	for i, op := range ops {
+		_, hasValue := raw[i]["value"]
+		switch {
+		case op.Op == "":
+			return nil, fmt.Errorf("invalid JSON Patch: operation %d has no op", i+1)
+		case (op.Op == "add" || op.Op == "replace" || op.Op == "test") && !hasValue:
+			return nil, fmt.Errorf("invalid JSON Patch: operation %d (%s %s) has no value", i+1, op.Op, op.Path)
+		case (op.Op == "move" || op.Op == "copy") && raw[i]["from"] == nil:
+			return nil, fmt.Errorf("invalid JSON Patch: operation %d (%s %s) has no from", i+1, op.Op, op.Path)
+		}
+	}
+	return ops, nil
+}
+
+// Splits a JSON Pointer into its unescaped reference tokens, "" being the whole document
+func tokens(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer %q, expected it to start with /", pointer)
+	}
+	parts := strings.Split(pointer[1:], "/")
+	for i, p := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(p)
+	}
+	return parts, nil
+}
+
+// Index of an array element, n being allowed as the position after the last one
+func index(token string, n int, end bool) (int, error) {
+	if end && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || (!end && i == n) {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func get(doc any, pointer string) (any, error) {
+	parts, err := tokens(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range parts {
+		switch v := doc.(type) {
+		case map[string]any:
+			e, ok := v[p]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+			doc = e
+		case []any:
+			i, err := index(p, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%s not found", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// Sets the value at pointer, inserting it in arrays, returns the updated document
+func add(doc any, pointer string, value any) (any, error) {
+	parts, err := tokens(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	last := parts[len(parts)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		v[last] = value
+		return doc, nil
+	case []any:
+		i, err := index(last, len(v), true)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v[:i], append([]any{value}, v[i:]...)...)
+		return replaceParent(doc, pointer, v)
+	}
+	return nil, fmt.Errorf("parent of %s isn't an object or array", pointer)
+}
+
+// Removes the value at pointer, returns the updated document and the value removed
+func remove(doc any, pointer string) (any, any, error) {
+	parts, err := tokens(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parts) == 0 {
+		return nil, doc, nil
+	}
+	value, err := get(doc, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	parent, _ := get(doc, pointer[:strings.LastIndex(pointer, "/")])
+	last := parts[len(parts)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		delete(v, last)
+		return doc, value, nil
+	case []any:
+		i, _ := index(last, len(v), false)
+		doc, err := replaceParent(doc, pointer, append(v[:i:i], v[i+1:]...))
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("parent of %s isn't an object or array", pointer)
+}
+
+// Replaces the array holding the target of pointer, arrays change length so their parent must point to the new one
+func replaceParent(doc any, pointer string, array []any) (any, error) {
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	if parentPointer == "" {
+		return array, nil
+	}
+	grandparent, err := get(doc, parentPointer[:strings.LastIndex(parentPointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	parts, _ := tokens(parentPointer)
+	last := parts[len(parts)-1]
+	switch v := grandparent.(type) {
+	case map[string]any:
+		v[last] = array
+	case []any:
+		i, _ := index(last, len(v), false)
+		v[i] = array
+	}
+	return doc, nil
+}
+
+// Deep copy of a decoded JSON value
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = clone(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = clone(e)
+		}
+		return c
+	}
+	return v
+}
+
+func encode(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,128 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %s", s, err)
+	}
+	return v
+}
+
+// Examples of RFC 7386, appendix A
+func TestMerge(t *testing.T) {
+	tests := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		doc := decode(t, tt.doc)
+		if got := encode(Merge(doc, decode(t, tt.patch))); got != tt.want {
+			t.Errorf("Merge(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+		if got := encode(doc); got != encode(decode(t, tt.doc)) {
+			t.Errorf("Merge modified the document %s into %s", tt.doc, got)
+		}
+	}
+}
+
+// Examples of RFC 6902, appendix A
+func TestApply(t *testing.T) {
+	tests := []struct{ doc, ops, want string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"copy","from":"/~1","path":"/a"}]`, `{"/":9,"a":9,"~1":10}`},
+	}
+	for _, tt := range tests {
+		var ops []Operation
+		if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+			t.Fatal(err)
+		}
+		doc := decode(t, tt.doc)
+		got, err := Apply(doc, ops)
+		if err != nil {
+			t.Errorf("Apply(%s, %s): %s", tt.doc, tt.ops, err)
+			continue
+		}
+		if encode(got) != tt.want {
+			t.Errorf("Apply(%s, %s) = %s, want %s", tt.doc, tt.ops, encode(got), tt.want)
+		}
+		if got := encode(doc); got != encode(decode(t, tt.doc)) {
+			t.Errorf("Apply modified the document %s into %s", tt.doc, got)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct{ doc, ops string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"x"}]`},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/nope"}]`},
+		{`{"foo":{"a":1}}`, `[{"op":"move","from":"/foo","path":"/foo/b"}]`},
+		{`{"foo":"bar"}`, `[{"op":"frobnicate","path":"/foo"}]`},
+	}
+	for _, tt := range tests {
+		var ops []Operation
+		if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Apply(decode(t, tt.doc), ops); err == nil {
+			t.Errorf("Apply(%s, %s) = %s, want an error", tt.doc, tt.ops, encode(got))
+		}
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	tests := []struct{ base, mine, theirs, want, conflicts string }{
+		{`{"a":1,"b":1}`, `{"a":2,"b":1}`, `{"a":1,"b":3}`, `{"a":2,"b":3}`, `null`},
+		{`{"a":1}`, `{"a":1,"b":2}`, `{}`, `{"b":2}`, `null`},
+		{`{"a":1,"b":1}`, `{"a":2,"b":1}`, `{"a":2,"b":1}`, `{"a":2,"b":1}`, `null`},
+		{`{"a":1,"b":1,"c":1}`, `{"a":2,"b":2,"c":1}`, `{"a":3,"b":2}`, `{"b":2}`, `["a"]`},
+		{`{"tags":["a"]}`, `{"tags":["a","b"]}`, `{"tags":["c"]}`, `{}`, `["tags"]`},
+	}
+	for _, tt := range tests {
+		obj := func(s string) map[string]any { return decode(t, s).(map[string]any) }
+		got, conflicts := Merge3(obj(tt.base), obj(tt.mine), obj(tt.theirs))
+		if encode(got) != tt.want || encode(conflicts) != tt.conflicts {
+			t.Errorf("Merge3(%s, %s, %s) = %s, %s, want %s, %s", tt.base, tt.mine, tt.theirs, encode(got), encode(conflicts), tt.want, tt.conflicts)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct{ fields, current, want string }{
+		{`{"a":"x"}`, `{"a":"y","b":"z","n":4,"ok":true,"tags":["t"],"o":{"k":1},"x":null}`, `{"a":"x","b":"","n":0,"o":{},"ok":false,"tags":[],"x":null}`},
+		{`{"a":"x","n":0}`, `{"n":4}`, `{"a":"x","n":0}`},
+		{`{}`, `{}`, `{}`},
+	}
+	for _, tt := range tests {
+		obj := func(s string) map[string]any { return decode(t, s).(map[string]any) }
+		if got := encode(Replace(obj(tt.fields), obj(tt.current))); got != tt.want {
+			t.Errorf("Replace(%s, %s) = %s, want %s", tt.fields, tt.current, got, tt.want)
+		}
+	}
+}
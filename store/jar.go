@@ -0,0 +1,68 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hscli/logging"
+	"io"
+
+	"go.nhat.io/cookiejar"
+)
+
+// Cookie jar serializer encrypting the jar file.
+// Plaintext jars are still read, and encrypted on the next write
+type JarSerDer struct {
+	Passphrase func() (string, error) // called when the jar is first read or written
+
+	loadErr error // a jar that failed decrypting is never overwritten
+}
+
+func (s *JarSerDer) Serialize(w io.Writer, entries map[string]map[string]cookiejar.Entry) error {
+	if s.loadErr != nil {
+		return fmt.Errorf("not saving cookie jar: %w", s.loadErr)
+	}
+	passphrase, err := s.Passphrase()
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	data, err := Seal(passphrase, plaintext)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (s *JarSerDer) Deserialize(r io.Reader) (map[string]map[string]cookiejar.Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if IsSealed(data) {
+		passphrase, err := s.Passphrase()
+		if err != nil {
+			s.loadErr = err
+			return nil, err
+		}
+		data, err = Open(passphrase, data)
+		if err != nil {
+			logging.LogError("Failed decrypting cookie jar: %s", err)
+			s.loadErr = err
+			return nil, err
+		}
+	}
+
+	var entries map[string]map[string]cookiejar.Entry
+	if len(bytes.TrimSpace(data)) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return entries, nil
+}
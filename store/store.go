@@ -0,0 +1,119 @@
+// Encryption at rest of the cookie jar and saved credentials.
+// Data is sealed with AES-256-GCM using a key derived from a passphrase with scrypt
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrNotSealed        = errors.New("data is not encrypted")
+	ErrWrongPassphrase  = errors.New("wrong passphrase or corrupted data")
+	ErrNoCredentials    = errors.New("no saved credentials")
+	magic               = []byte("HSENC1\n")
+	saltSize, nonceSize = 16, 12
+)
+
+// scrypt parameters, see https://pkg.go.dev/golang.org/x/crypto/scrypt
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keySize = 32
+)
+
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Encrypts plaintext with a key derived from passphrase.
+// Output is the magic header followed by the salt, nonce and ciphertext
+func Seal(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("rand.Read: %w", err)
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("rand.Read: %w", err)
+	}
+
+	out := append([]byte{}, magic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Decrypts data produced by Seal
+func Open(passphrase string, data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, ErrNotSealed
+	}
+	data = data[len(magic):]
+	if len(data) < saltSize+nonceSize {
+		return nil, ErrWrongPassphrase
+	}
+	salt, nonce, ciphertext := data[:saltSize], data[saltSize:saltSize+nonceSize], data[saltSize+nonceSize:]
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt.Key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Saves the password encrypted at path
+func SaveCredentials(path string, passphrase string, password string) error {
+	data, err := Seal(passphrase, []byte(password))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Loads a password saved with SaveCredentials
+func LoadCredentials(path string, passphrase string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNoCredentials
+		}
+		return "", err
+	}
+	password, err := Open(passphrase, data)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}